@@ -0,0 +1,94 @@
+package client
+
+import (
+	"fmt"
+	"hybrid-clock/hlc"
+	"hybrid-clock/trace"
+	"sync"
+	"time"
+)
+
+type Client struct{
+	Id int
+	SendChannel chan Message
+	ReceiveChannel chan Message
+	Clock hlc.Clock
+	SeqReceived int // every sequence number up to this one has been received from the server
+	SeqAhead map[int]bool // sequence numbers received out of order, beyond SeqReceived
+	Trace *trace.Recorder // records the client's events, nil if tracing is disabled
+	SendInterval time.Duration // time between two messages of the client
+	Messages int // messages the client sends before it stops, 0 if it keeps sending until the run ends
+	Stop chan struct{} // closed to make the client stop sending, nil if it never does
+	Lock sync.Mutex
+}
+
+// send message function to server
+func (c *Client) SendMessage() {
+	for sent := 1; ; sent++{
+		select {
+		case <-c.Stop:
+			return
+		default:
+		}
+
+		c.Lock.Lock()
+		step := c.Clock.Now()
+		message := Message{Type: MESSAGE, Clock: step.After, Message: fmt.Sprintf("Hello from client %d", c.Id), ClientId: c.Id, MessageId: c.Trace.NewMessageId(trace.Client(c.Id))}
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-HLC%v] Sending message to server: '%s'", c.Id, step.After, message.Message))
+		c.Trace.Record(trace.Event{Node: trace.Client(c.Id), Type: trace.SEND, Peer: trace.SERVER, MessageId: message.MessageId, ClockBefore: step.Before, Clock: step.After, Physical: step.Physical, Description: message.Message})
+		c.Lock.Unlock()
+
+		c.SendChannel <- message
+		if sent == c.Messages {
+			return
+		}
+
+		// each message is sent every send interval
+		select {
+		case <-c.Stop:
+		case <-time.After(c.SendInterval):
+		}
+	}
+}
+
+func (c *Client) ReceiveMessage(){
+	for{
+		msg := <- c.ReceiveChannel
+
+		// Acknowledging every copy since the acknowledgement of an earlier copy might not have reached the server in time
+		go c.acknowledge(msg.Seq)
+
+		c.Lock.Lock()
+		if c.isRetransmission(msg.Seq) {
+			fmt.Println(fmt.Sprintf("[CLIENT-%d-HLC%v] Duplicate of message %d discarded: '%s'", c.Id, c.Clock.Last, msg.Seq, msg.Message))
+			c.Lock.Unlock()
+			continue
+		}
+
+		// updating the clock past the larger of its own timestamp, the message's timestamp and the physical clock
+		step := c.Clock.Update(msg.Clock)
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-HLC%v] Message received from server: '%s'. Ahead of the physical clock by %v", c.Id, step.After, msg.Message, step.Divergence()))
+		c.Trace.Record(trace.Event{Node: trace.Client(c.Id), Type: trace.RECEIVE, Peer: trace.SERVER, MessageId: msg.MessageId, ClockBefore: step.Before, Clock: step.After, Physical: step.Physical, Description: msg.Message})
+		c.Lock.Unlock()
+	}
+}
+
+// sends an acknowledgement for a message forwarded by the server.
+// Acknowledgements belong to the delivery layer, so they do not change the hybrid clock.
+func (c *Client) acknowledge(seq int) {
+	c.SendChannel <- Message{Type: ACK, ClientId: c.Id, Seq: seq}
+}
+
+// Checking if a message with this sequence number has already been received, recording it otherwise
+func (c *Client) isRetransmission(seq int) bool {
+	if seq <= c.SeqReceived || c.SeqAhead[seq] {
+		return true
+	}
+
+	c.SeqAhead[seq] = true
+	for c.SeqAhead[c.SeqReceived + 1] {
+		delete(c.SeqAhead, c.SeqReceived + 1)
+		c.SeqReceived += 1
+	}
+	return false
+}
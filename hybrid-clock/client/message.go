@@ -0,0 +1,19 @@
+package client
+
+import (
+	"hybrid-clock/hlc"
+)
+
+const (
+	MESSAGE = "MESSAGE" // Message broadcast by a client to every other client
+	ACK     = "ACK"     // Acknowledgement of a message forwarded by the server
+)
+
+type Message struct{
+	Type string // MESSAGE | ACK
+	Clock hlc.Timestamp
+	Message string
+	ClientId int
+	Seq int // sequence number of the message on the link from the server to the recipient, 0 for messages sent by clients
+	MessageId string // identifies the send event of the message in the trace
+}
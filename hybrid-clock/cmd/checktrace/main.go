@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"hybrid-clock/trace"
+	"os"
+	"time"
+)
+
+// Checks an event log written with -log for violations of the hybrid logical clock invariants
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Println("Usage: checktrace events.jsonl")
+		os.Exit(2)
+	}
+
+	file, err := os.Open(os.Args[1])
+	if err != nil {
+		fmt.Println("Error occurred while opening the event log: ", err)
+		os.Exit(2)
+	}
+	defer file.Close()
+
+	events, err := trace.ReadEvents(file)
+	if err != nil {
+		fmt.Println("Error occurred while reading the event log: ", err)
+		os.Exit(2)
+	}
+
+	violations := trace.Check(events)
+	for _, violation := range violations {
+		fmt.Println(violation)
+	}
+
+	// The hybrid clock of every node should stay within the largest offset between the physical clocks
+	divergence := make(map[string]time.Duration)
+	for _, event := range events {
+		if event.Type != trace.DROP {
+			divergence[event.Node] = max(divergence[event.Node], trace.Divergence(event))
+		}
+	}
+	for _, node := range trace.Nodes(events) {
+		fmt.Printf("[CHECK] Largest divergence of %s from its physical clock: %v\n", node, divergence[node])
+	}
+
+	fmt.Printf("[CHECK] %d events checked, %d violations found\n", len(events), len(violations))
+	if len(violations) > 0 {
+		os.Exit(1)
+	}
+}
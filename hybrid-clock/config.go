@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// sets the flags given by name in a JSON config file, like {"clients": 5, "send-interval": "2s", "drop-rate": 0.2}.
+// A flag also given on the command line keeps the value from the command line
+func loadConfig(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// numbers are kept as they were written, so large ones are not turned into floats
+	decoder := json.NewDecoder(file)
+	decoder.UseNumber()
+	var values map[string]any
+	if err := decoder.Decode(&values); err != nil {
+		return err
+	}
+
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, value := range values {
+		if flag.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag %q", name)
+		}
+		if given[name] {
+			continue
+		}
+		if err := flag.Set(name, fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("invalid value %v for flag %q: %w", value, name, err)
+		}
+	}
+	return nil
+}
+
+// Checking if a flag was given on the command line or in the config file
+func isSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -0,0 +1,3 @@
+module hybrid-clock
+
+go 1.23.2
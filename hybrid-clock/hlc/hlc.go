@@ -0,0 +1,84 @@
+package hlc
+
+import (
+	"cmp"
+	"fmt"
+	"time"
+)
+
+// Timestamp of a hybrid logical clock. Physical is the largest physical time the node has heard of,
+// in milliseconds since the Unix epoch, and Logical orders the events that share the same physical time.
+type Timestamp struct {
+	Physical int64 `json:"physical"`
+	Logical int `json:"logical"`
+}
+
+func (t Timestamp) String() string {
+	return fmt.Sprintf("%s+%d", time.UnixMilli(t.Physical).Format("15:04:05.000"), t.Logical)
+}
+
+// Comparing two timestamps by their physical time first and their logical counter second
+func Compare(t1 Timestamp, t2 Timestamp) int {
+	return cmp.Or(cmp.Compare(t1.Physical, t2.Physical), cmp.Compare(t1.Logical, t2.Logical))
+}
+
+// Hybrid logical clock of a node, following Kulkarni et al., "Logical Physical Clocks".
+// The clock is never behind the node's physical clock and never further ahead of it than the
+// largest offset between the physical clocks of the nodes it has heard from.
+// It is not safe for concurrent use, so the node has to hold its lock while using it.
+type Clock struct {
+	Physical *PhysicalClock
+	Last Timestamp // timestamp of the node's last event
+	MaxDivergence time.Duration // largest distance seen between the hybrid clock and the physical clock
+	MaxLogical int // largest logical counter seen, which stays small as long as the physical clocks advance
+}
+
+// Step is the change of the clock made by a single event
+type Step struct {
+	Before Timestamp
+	After Timestamp
+	Physical int64 // physical clock of the node when the event happened
+}
+
+// Divergence of the hybrid clock from the physical clock after the step
+func (s Step) Divergence() time.Duration {
+	return time.Duration(s.After.Physical - s.Physical) * time.Millisecond
+}
+
+// Advancing the clock for a local event or the send of a message
+func (c *Clock) Now() Step {
+	step := Step{Before: c.Last, Physical: c.Physical.Now()}
+
+	c.Last.Physical = max(step.Before.Physical, step.Physical)
+	if c.Last.Physical == step.Before.Physical {
+		c.Last.Logical = step.Before.Logical + 1
+	} else {
+		c.Last.Logical = 0
+	}
+	return c.record(step)
+}
+
+// Advancing the clock past the timestamp of a received message
+func (c *Clock) Update(remote Timestamp) Step {
+	step := Step{Before: c.Last, Physical: c.Physical.Now()}
+
+	c.Last.Physical = max(step.Before.Physical, remote.Physical, step.Physical)
+	switch {
+	case c.Last.Physical == step.Before.Physical && c.Last.Physical == remote.Physical:
+		c.Last.Logical = max(step.Before.Logical, remote.Logical) + 1
+	case c.Last.Physical == step.Before.Physical:
+		c.Last.Logical = step.Before.Logical + 1
+	case c.Last.Physical == remote.Physical:
+		c.Last.Logical = remote.Logical + 1
+	default:
+		c.Last.Logical = 0
+	}
+	return c.record(step)
+}
+
+func (c *Clock) record(step Step) Step {
+	step.After = c.Last
+	c.MaxDivergence = max(c.MaxDivergence, step.Divergence())
+	c.MaxLogical = max(c.MaxLogical, step.After.Logical)
+	return step
+}
@@ -0,0 +1,37 @@
+package hlc
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// PhysicalClock simulates the wall clock of a node, which starts off with an offset from the real time
+// and then runs too fast or too slow by a constant rate
+type PhysicalClock struct {
+	Offset time.Duration // difference from the real time when the clock was created
+	Drift float64 // rate error of the clock, 0.01 makes it run 1% fast and -0.01 1% slow
+	origin time.Time // real time when the clock was created
+}
+
+func NewPhysicalClock(offset time.Duration, drift float64) *PhysicalClock {
+	return &PhysicalClock{Offset: offset, Drift: drift, origin: time.Now()}
+}
+
+// Creates a physical clock with an offset of up to maxOffset and a drift of up to maxDrift in either direction
+func RandomPhysicalClock(rng *rand.Rand, maxOffset time.Duration, maxDrift float64) *PhysicalClock {
+	offset := time.Duration((rng.Float64() * 2 - 1) * float64(maxOffset)).Round(time.Millisecond)
+	drift := (rng.Float64() * 2 - 1) * maxDrift
+	return NewPhysicalClock(offset, drift)
+}
+
+// Reading of the clock in milliseconds since the Unix epoch
+func (p *PhysicalClock) Now() int64 {
+	elapsed := time.Since(p.origin)
+	skewed := time.Duration(float64(elapsed) * (1 + p.Drift))
+	return p.origin.Add(p.Offset + skewed).UnixMilli()
+}
+
+func (p *PhysicalClock) String() string {
+	return fmt.Sprintf("offset %v, drift %+.2f%%", p.Offset, p.Drift * 100)
+}
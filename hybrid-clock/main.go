@@ -0,0 +1,265 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hybrid-clock/client"
+	"hybrid-clock/hlc"
+	"hybrid-clock/server"
+	"hybrid-clock/trace"
+	"io"
+	"math/rand"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const(
+	DrainTimeout = 30 * time.Second // how long a bounded run waits for the messages still in flight once the clients have stopped sending
+)
+
+var numNodes = 3 // number of clients, changed with -clients
+
+// how often the clients send their messages and when they stop
+type bounds struct {
+	interval time.Duration
+	duration time.Duration // how long the clients send messages for, 0 if they send until the user presses enter
+	messages int // messages every client sends, 0 if they send until the user presses enter
+	stop chan struct{} // closed once the duration is over to make the clients stop sending
+	senders sync.WaitGroup // clients still sending messages
+}
+
+func main() {
+	configFile := flag.String("config", "", "JSON file setting any of these flags by name, the command line takes precedence over it")
+	flag.IntVar(&numNodes, "clients", numNodes, "number of clients")
+	sendInterval := flag.Duration("send-interval", 5 * time.Second, "interval at which every client sends a message")
+	dropRate := flag.Float64("drop-rate", 0.5, "probability that the network drops a message to a client, replaces the drop rate of the default link of the network configuration when given")
+	duration := flag.Duration("duration", 0, "how long the clients send messages before the run ends with a summary, 0 runs until enter is pressed")
+	messages := flag.Int("messages", 0, "number of messages every client sends before the run ends with a summary, 0 runs until enter is pressed")
+	networkFile := flag.String("network", "", "JSON file configuring drops, latency, reordering and duplication per link")
+	seed := flag.Int64("seed", 0, "seed of the network's random number generator, 0 picks one from the current time")
+	dotFile := flag.String("dot", "", "file to write a Graphviz space-time diagram of the run to when it ends")
+	svgFile := flag.String("svg", "", "file to write an SVG space-time diagram of the run to when it ends")
+	shivizFile := flag.String("shiviz", "", "file to write a ShiViz log of the run to when it ends")
+	maxOffset := flag.Duration("max-offset", 500 * time.Millisecond, "largest offset of a node's physical clock from the real time when it starts")
+	maxDrift := flag.Float64("max-drift", 0.02, "largest rate error of a node's physical clock, 0.02 lets it run up to 2% fast or slow")
+	logFile := flag.String("log", "", "file to write every event to as a JSON line while the program runs, to be checked with cmd/checktrace")
+	flag.Parse()
+
+	if *configFile != "" {
+		if err := loadConfig(*configFile); err != nil {
+			fmt.Println("Error occurred while reading the config file: ", err)
+			os.Exit(1)
+		}
+	}
+	if numNodes < 2 {
+		fmt.Println("There must be at least two clients")
+		os.Exit(1)
+	}
+	if *sendInterval <= 0 || *duration < 0 || *messages < 0 {
+		fmt.Println("The send interval must be positive, and the duration and the number of messages cannot be negative")
+		os.Exit(1)
+	}
+	if *dropRate < 0 || *dropRate > 1 {
+		fmt.Println("The drop rate must be between 0 and 1")
+		os.Exit(1)
+	}
+	bounds := &bounds{interval: *sendInterval, duration: *duration, messages: *messages, stop: make(chan struct{})}
+
+	var recorder *trace.Recorder
+	// a bounded run ends with a summary of the trace
+	if *dotFile != "" || *svgFile != "" || *shivizFile != "" || *logFile != "" || bounds.bounded() {
+		recorder = &trace.Recorder{}
+	}
+	if *logFile != "" {
+		file, err := os.Create(*logFile)
+		if err != nil {
+			fmt.Println("Error occurred while creating the event log: ", err)
+			os.Exit(1)
+		}
+		recorder.Output = file
+		defer recorder.Close()
+	}
+
+	networkConfig := server.DefaultNetworkConfig()
+	if *networkFile != "" {
+		var err error
+		networkConfig, err = server.LoadNetworkConfig(*networkFile)
+		if err != nil {
+			fmt.Println("Error occurred while reading the network configuration: ", err)
+			os.Exit(1)
+		}
+	}
+	if *seed != 0 {
+		networkConfig.Seed = *seed
+	}
+	if isSet("drop-rate") {
+		networkConfig.Default.DropRate = *dropRate
+	}
+	network := server.NewSimulatedNetwork(networkConfig)
+	fmt.Printf("[NETWORK] Random number generator seeded with %d\n", network.Config.Seed)
+
+	// the physical clocks of the nodes are skewed from the same seed so a run can be reproduced
+	clockRng := rand.New(rand.NewSource(network.Config.Seed))
+
+	clientChannels := make([]chan client.Message, numNodes)  // creating a slice of channels for server to send messages to the clients
+	serverChannels := make([]chan client.Message, numNodes)  // creating a slice of channels for clients to send messages to the server
+
+	for i := range clientChannels {
+		clientChannels[i] = make(chan client.Message)
+		serverChannels[i] = make(chan client.Message)
+	}
+
+	server := server.Server{
+		Clock: hlc.Clock{Physical: hlc.RandomPhysicalClock(clockRng, *maxOffset, *maxDrift)}, // the server starts off with a timestamp of 0 and its own skewed physical clock
+		SendChannels: clientChannels,
+		ReceiveChannels: serverChannels,
+		Network: network,
+		NextSeq: make(map[int]int),
+		Pending: make(map[int]map[int]*server.PendingMessage),
+		Trace: recorder,
+	}
+
+	fmt.Printf("[SERVER] Physical clock %v\n", server.Clock.Physical)
+
+	go server.ReceiveMessage()
+	go server.RetransmitMessages()
+	clients := make([]*client.Client, numNodes)
+	for i := range clientChannels {
+		client := &client.Client{
+			Id: int(i),
+			SendChannel: serverChannels[i],
+			ReceiveChannel: clientChannels[i],
+			Clock: hlc.Clock{Physical: hlc.RandomPhysicalClock(clockRng, *maxOffset, *maxDrift)}, // every client starts off with a timestamp of 0 and its own skewed physical clock
+			SeqAhead: make(map[int]bool),
+			Trace: recorder,
+			SendInterval: bounds.interval,
+			Messages: bounds.messages,
+			Stop: bounds.stop,
+		}
+		clients[i] = client
+		fmt.Printf("[CLIENT-%d] Physical clock %v\n", client.Id, client.Clock.Physical)
+	}
+	for _, client := range clients {
+		bounds.senders.Add(1)
+		go func() {
+			defer bounds.senders.Done()
+			client.SendMessage()
+		}()
+		go client.ReceiveMessage()
+	}
+
+	bounded := waitForEnd(bounds)
+	if bounded {
+		settle(&server)
+	}
+
+	summarizeClocks(&server, clients)
+
+	events := recorder.Events()
+	exportTrace(events, *dotFile, trace.WriteDOT)
+	exportTrace(events, *svgFile, trace.WriteSVG)
+	exportTrace(events, *shivizFile, trace.WriteShiViz)
+	if bounded {
+		for _, line := range trace.Summarize(events).Lines() {
+			fmt.Println("[SUMMARY]", line)
+		}
+	}
+}
+
+// waits until the user presses enter, or until every client has stopped sending in a bounded run. Returns true if
+// the run ended because it reached its bounds. A bounded run does not read the standard input, so it can run
+// unattended with nothing to read
+func waitForEnd(bounds *bounds) bool {
+	if !bounds.bounded() {
+		var input string
+		fmt.Scanln(&input)
+		return false
+	}
+
+	<-bounds.end()
+	fmt.Println("[RUN] Every client has stopped sending messages")
+	return true
+}
+
+// Checking if the run ends on its own after a duration or a number of messages
+func (b *bounds) bounded() bool {
+	return b.duration > 0 || b.messages > 0
+}
+
+// starts counting down the duration of a bounded run and returns a channel closed once every client has stopped
+// sending, nil if the run is not bounded
+func (b *bounds) end() <-chan struct{} {
+	if !b.bounded() {
+		return nil
+	}
+
+	if b.duration > 0 {
+		time.AfterFunc(b.duration, func() {
+			close(b.stop)
+		})
+	}
+	ended := make(chan struct{})
+	go func() {
+		b.senders.Wait()
+		close(ended)
+	}()
+	return ended
+}
+
+// waits until the server has had every message it forwarded acknowledged, so every message of a bounded run
+// has been delivered or given up on by the time it is summarized. Gives up after DrainTimeout
+func settle(s *server.Server) {
+	deadline := time.Now().Add(DrainTimeout)
+	idle := 0 // consecutive checks without a message in flight, since the last message the server received may not be forwarded yet
+	for {
+		unacknowledged := s.Unacknowledged()
+		if unacknowledged == 0 {
+			idle += 1
+		} else {
+			idle = 0
+		}
+		if idle == 2 {
+			fmt.Println("[RUN] Every message has been acknowledged")
+			return
+		}
+		if time.Now().After(deadline) {
+			fmt.Printf("[RUN] %d messages still unacknowledged after %v\n", unacknowledged, DrainTimeout)
+			return
+		}
+		time.Sleep(server.RetransmitInterval)
+	}
+}
+
+// writes the recorded events to a file in one of the export formats, if a file was given
+func exportTrace(events []trace.Event, path string, write func(io.Writer, []trace.Event) error) {
+	if path == "" {
+		return
+	}
+
+	if err := trace.WriteFile(path, write, events); err != nil {
+		fmt.Printf("Error occurred while writing %s: %s\n", path, err)
+		return
+	}
+	fmt.Printf("[TRACE] %d events written to %s\n", len(events), path)
+}
+
+// prints how far the hybrid clock of every node got ahead of its physical clock, which stays within
+// the largest skew between the physical clocks even though the skew keeps growing with the drift
+func summarizeClocks(server *server.Server, clients []*client.Client) {
+	server.Lock.Lock()
+	readings := []int64{server.Clock.Physical.Now()}
+	fmt.Printf("[SERVER] Largest divergence from the physical clock: %v, largest logical counter: %d\n", server.Clock.MaxDivergence, server.Clock.MaxLogical)
+	server.Lock.Unlock()
+
+	for _, client := range clients {
+		client.Lock.Lock()
+		readings = append(readings, client.Clock.Physical.Now())
+		fmt.Printf("[CLIENT-%d] Largest divergence from the physical clock: %v, largest logical counter: %d\n", client.Id, client.Clock.MaxDivergence, client.Clock.MaxLogical)
+		client.Lock.Unlock()
+	}
+
+	skew := time.Duration(slices.Max(readings) - slices.Min(readings)) * time.Millisecond
+	fmt.Printf("[CLOCKS] Current skew between the physical clocks: %v\n", skew)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"fmt"
+	"hybrid-clock/client"
+	"slices"
+	"time"
+)
+
+const (
+	AckTimeout = 2 * time.Second // how long the server waits for an acknowledgement before retransmitting
+	RetransmitInterval = 500 * time.Millisecond // how often the server looks for messages to retransmit
+)
+
+// forwarded message waiting for an acknowledgement from its recipient
+type PendingMessage struct {
+	Message client.Message
+	SentAt time.Time
+	Attempts int
+}
+
+// function to retransmit every forwarded message that has not been acknowledged in time.
+// A retransmission carries the clock of the original forward since it is the same send event
+// as far as the logical clock is concerned, so it does not increment the server's clock.
+func (s *Server) RetransmitMessages(){
+	for{
+		time.Sleep(RetransmitInterval)
+
+		for i := range s.SendChannels{
+			s.Lock.Lock()
+			expired := make([]client.Message, 0)
+			for _, pending := range s.Pending[i]{
+				if time.Since(pending.SentAt) >= AckTimeout {
+					pending.SentAt = time.Now()
+					pending.Attempts += 1
+					expired = append(expired, pending.Message)
+				}
+			}
+			s.Lock.Unlock()
+
+			slices.SortFunc(expired, func(a, b client.Message) int { return a.Seq - b.Seq })
+			for _, message := range expired{
+				fmt.Println(fmt.Sprintf("[SERVER-HLC%v] No acknowledgement from client %d for message %d, retransmitting: '%s'", message.Clock, i, message.Seq, message.Message))
+				s.transmit(i, message)
+			}
+		}
+	}
+}
+
+// function to stop retransmitting a message once its recipient has acknowledged it
+func (s *Server) acknowledge(clientId int, seq int){
+	s.Lock.Lock()
+	pending, ok := s.Pending[clientId][seq]
+	delete(s.Pending[clientId], seq)
+	s.Lock.Unlock()
+
+	if ok && pending.Attempts > 1 {
+		fmt.Println(fmt.Sprintf("[SERVER-HLC%v] Message %d acknowledged by client %d after %d transmissions", pending.Message.Clock, seq, clientId, pending.Attempts))
+	}
+}
+
+// function to count the forwarded messages still waiting for an acknowledgement, to tell when a run has settled
+func (s *Server) Unacknowledged() int {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	count := 0
+	for _, messages := range s.Pending{
+		count += len(messages)
+	}
+	return count
+}
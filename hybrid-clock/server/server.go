@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"hybrid-clock/client"
+	"hybrid-clock/hlc"
+	"hybrid-clock/trace"
+	"sync"
+	"time"
+)
+
+type Server struct {
+	Clock hlc.Clock
+	SendChannels []chan client.Message
+	ReceiveChannels []chan client.Message
+	Network NetworkModel // decides how every forwarded message travels to its recipient
+	NextSeq map[int]int // last sequence number used on the link to each client
+	Pending map[int]map[int]*PendingMessage // forwarded messages not acknowledged yet, by client and sequence number
+	Trace *trace.Recorder // records the server's events, nil if tracing is disabled
+	Lock sync.Mutex
+}
+
+// function to receive messages from client
+func (s *Server) ReceiveMessage(){
+	for i := range s.ReceiveChannels{
+		go s.handleClientChannels(i)
+	}
+}
+
+// function to handle all client channels
+func (s *Server) handleClientChannels(clientId int){
+	for{
+		msg := <- s.ReceiveChannels[clientId]
+
+		if msg.Type == client.ACK {
+			s.acknowledge(clientId, msg.Seq)
+			continue
+		}
+
+		s.Lock.Lock()
+		step := s.Clock.Update(msg.Clock)
+		fmt.Println(fmt.Sprintf("[SERVER-HLC%v] Message receieved: '%s'. Ahead of the physical clock by %v", step.After, msg.Message, step.Divergence()))
+		s.Trace.Record(trace.Event{Node: trace.SERVER, Type: trace.RECEIVE, Peer: trace.Client(clientId), MessageId: msg.MessageId, ClockBefore: step.Before, Clock: step.After, Physical: step.Physical, Description: msg.Message})
+		s.Lock.Unlock()
+
+		if msg != (client.Message{}) {
+			// send to all clients which don't have id as clientId
+			s.sendMessage(msg)
+		}
+	}
+}
+
+// function to send message to clients except the one who sent the message
+func (s *Server) sendMessage(message client.Message){
+
+	for i := range s.SendChannels{
+		if i != message.ClientId{
+
+			s.Lock.Lock()
+			step := s.Clock.Now()
+			s.NextSeq[i] += 1
+			if s.Pending[i] == nil {
+				s.Pending[i] = make(map[int]*PendingMessage)
+			}
+			forwarded := client.Message{Type: client.MESSAGE, Clock: step.After, Message: message.Message, ClientId: message.ClientId, Seq: s.NextSeq[i], MessageId: s.Trace.NewMessageId(trace.SERVER)}
+			s.Trace.Record(trace.Event{Node: trace.SERVER, Type: trace.SEND, Peer: trace.Client(i), MessageId: forwarded.MessageId, ClockBefore: step.Before, Clock: step.After, Physical: step.Physical, Description: message.Message})
+			s.Pending[i][forwarded.Seq] = &PendingMessage{Message: forwarded, SentAt: time.Now(), Attempts: 1}
+			s.Lock.Unlock()
+
+			s.transmit(i, forwarded)
+		}
+	}
+}
+
+// function to send a message over the link to a client, which may drop, delay or duplicate it
+func (s *Server) transmit(clientId int, message client.Message){
+	channel := s.SendChannels[clientId]
+
+	// the network model decides whether the message reaches the client, how long it takes and how many copies arrive
+	transmission := s.Network.Transmit(clientId)
+
+	if transmission.Dropped {
+		fmt.Println(fmt.Sprintf("[SERVER-HLC%v] Forwarding the message of client %d to client %d is dropped", message.Clock, message.ClientId, clientId))
+		s.Trace.Record(trace.Event{Node: trace.SERVER, Type: trace.DROP, Peer: trace.Client(clientId), MessageId: message.MessageId, ClockBefore: message.Clock, Clock: message.Clock, Description: message.Message})
+		return
+	}
+
+	for copyIndex, delay := range transmission.Delays{
+		if delay > 0 {
+			// the message is in transit while the server carries on
+			go func() {
+				time.Sleep(delay)
+				channel <- message
+			}()
+		} else {
+			channel <- message
+		}
+
+		if copyIndex > 0 {
+			fmt.Println(fmt.Sprintf("[SERVER-HLC%v] Message duplicated to client %d with a latency of %v: '%s'", message.Clock, clientId, delay, message.Message))
+		} else if delay > 0 {
+			fmt.Println(fmt.Sprintf("[SERVER-HLC%v] Message forwarded to client %d with a latency of %v: '%s'", message.Clock, clientId, delay, message.Message))
+		} else {
+			fmt.Println(fmt.Sprintf("[SERVER-HLC%v] Message forwarded to client %d: '%s'", message.Clock, clientId, message.Message))
+		}
+	}
+}
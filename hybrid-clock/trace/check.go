@@ -0,0 +1,70 @@
+package trace
+
+import (
+	"fmt"
+	"hybrid-clock/hlc"
+	"time"
+)
+
+// Invariants checked by Check
+const (
+	CLOCK_CONDITION = "clock condition" // a message is sent with a smaller clock than it is received with
+	MONOTONICITY    = "monotonicity" // every step of a node advances its clock, starting from the clock the previous step left
+	RECORDED_ORDER  = "recorded order" // a message is recorded as sent before it is recorded as received
+	PHYSICAL_TIME   = "physical time" // the hybrid clock is never behind the physical clock of its node
+)
+
+// Violation is an event that breaks one of the invariants of a logical clock
+type Violation struct {
+	Invariant string
+	Event Event
+	Detail string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s violated by %s %s: %s", v.Invariant, v.Event.Node, Describe(v.Event), v.Detail)
+}
+
+// Checks the hybrid logical clocks of the events in the order they were recorded.
+// A clock that was read or updated outside the lock shows up as a step that does not continue
+// from the previous step of the node, or as a message received with a clock it was not sent with.
+func Check(events []Event) []Violation {
+	violations := make([]Violation, 0)
+	sends := make(map[string]Event)
+	last := make(map[string]Event) // previous step of each node
+
+	for _, event := range events {
+		if event.Type == DROP {
+			continue
+		}
+
+		if previous, ok := last[event.Node]; ok && event.ClockBefore != previous.Clock {
+			violations = append(violations, Violation{MONOTONICITY, event, fmt.Sprintf("the clock was %v before the step but the previous step left it at %v", event.ClockBefore, previous.Clock)})
+		}
+		if hlc.Compare(event.Clock, event.ClockBefore) <= 0 {
+			violations = append(violations, Violation{MONOTONICITY, event, fmt.Sprintf("the clock went from %v to %v", event.ClockBefore, event.Clock)})
+		}
+		if event.Clock.Physical < event.Physical {
+			violations = append(violations, Violation{PHYSICAL_TIME, event, fmt.Sprintf("the clock is %v behind the physical clock", Divergence(event).Abs())})
+		}
+		last[event.Node] = event
+
+		switch event.Type {
+		case SEND:
+			sends[event.MessageId] = event
+		case RECEIVE:
+			send, ok := sends[event.MessageId]
+			if !ok {
+				violations = append(violations, Violation{RECORDED_ORDER, event, "the message was not recorded as sent before it was received"})
+			} else if hlc.Compare(send.Clock, event.Clock) >= 0 {
+				violations = append(violations, Violation{CLOCK_CONDITION, event, fmt.Sprintf("the message was sent with clock %v and received with clock %v", send.Clock, event.Clock)})
+			}
+		}
+	}
+	return violations
+}
+
+// Distance of the hybrid clock ahead of the physical clock of the node after the event
+func Divergence(event Event) time.Duration {
+	return time.Duration(event.Clock.Physical - event.Physical) * time.Millisecond
+}
@@ -2,31 +2,228 @@ package client
 
 import (
 	"fmt"
+	"lamports-clock/schedule"
+	"lamports-clock/snapshot"
+	"lamports-clock/trace"
+	"slices"
+	"sync"
 	"time"
 )
 
 type Client struct{
 	Id int
+	Server string // name of the client's server in the trace
 	SendChannel chan Message
 	ReceiveChannel chan Message
 	Clock int
+	SeqReceived int // every sequence number up to this one has been received from the server
+	SeqAhead map[int]bool // sequence numbers received out of order, beyond SeqReceived
+	Trace *trace.Recorder // records the client's events, nil if tracing is disabled
+	Schedule *schedule.Schedule // records the order of the client's steps or replays a recorded one, nil if it does neither
+	Arrived []Message // messages from the server waiting for the client's turn to handle them while a schedule is replayed
+	Sent int // messages sent to the server
+	Received int // messages received from the server
+	SnapshotId int // latest snapshot the client has recorded its state for
+	Recording *ChannelRecording // recording of the channel from the server, nil if no snapshot is being taken
+	Snapshots *snapshot.Collector // assembles the snapshots, nil if snapshots are disabled
+	Routing string // how the client addresses its messages: BROADCAST | DIRECT | LIST | TOPIC | MIXED
+	Peers []int // ids of the other clients, which the client may address its messages to
+	Topics []string // topics the client may address its messages to
+	Joined chan struct{} // closed once the client has received the server's JOIN message
+	Quit chan struct{} // closed to make the client leave the system
+	SendInterval time.Duration // time between two messages of the client
+	Messages int // messages the client sends before it stops, 0 if it keeps sending until the run ends
+	Stop chan struct{} // closed to make the client stop sending without leaving the system, nil if it never does
+	Stopped bool // the client has stopped sending, so a leave is sent by Leave itself
+	Validation *Validation // limits the clocks received from the server have to respect, nil if they are not validated
+	Faulty bool // the client corrupts the clock of some of its messages
+	Lock sync.Mutex
 }
 
 // send message function to server
 func (c *Client) SendMessage() {
+	// the client only starts sending once its clock has been initialised from the server's
+	select {
+	case <-c.Joined:
+	case <-c.Quit:
+		c.sendLeave()
+		return
+	case <-c.Stop:
+		c.stopSending()
+		return
+	}
+
 	for{
+		select {
+		case <-c.Quit:
+			c.sendLeave()
+			return
+		case <-c.Stop:
+			c.stopSending()
+			return
+		default:
+		}
+
+		step := c.Schedule.Await(schedule.Step{Node: trace.Client(c.Id), Kind: schedule.SEND})
+		c.Lock.Lock()
+		clockBefore := c.Clock
 		c.Clock += 1
-		message := Message{c.Clock, fmt.Sprintf("Hello from client %d", c.Id), c.Id}
-		fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Sending message to server: '%s'", c.Id, c.Clock, message.Message))
+		c.Sent += 1
+		message := Message{Type: MESSAGE, Clock: c.Clock, Message: fmt.Sprintf("Hello from client %d", c.Id), ClientId: c.Id, Seq: c.Sent, MessageId: c.Trace.NewMessageId(trace.Client(c.Id)), Snapshot: c.SnapshotId}
+		if c.Schedule.Replaying() {
+			message.To, message.Topic = step.To, step.Topic // addressed as in the recorded run
+		} else {
+			c.address(&message)
+		}
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Sending message to server for %s: '%s'", c.Id, c.Clock, message.Recipients(), message.Message))
+		c.Trace.Record(trace.Event{Node: trace.Client(c.Id), Type: trace.SEND, Peer: c.Server, MessageId: message.MessageId, ClockBefore: clockBefore, Clock: c.Clock, Description: message.Message})
+		c.Schedule.Done(schedule.Step{Node: trace.Client(c.Id), Kind: schedule.SEND, To: message.To, Topic: message.Topic})
+		c.corrupt(&message)
+		c.Lock.Unlock()
+
 		c.SendChannel <- message
-		time.Sleep(5 * time.Second) // each message is sent every 5 seconds
+		if c.Sent == c.Messages {
+			c.stopSending()
+			return
+		}
+
+		// each message is sent every send interval
+		select {
+		case <-c.Quit:
+		case <-c.Stop:
+		case <-time.After(c.SendInterval):
+		}
 	}
 }
 
 func (c *Client) ReceiveMessage(){
 	for{
-		msg := <- c.ReceiveChannel
+		msg := c.nextMessage()
+
+		c.Lock.Lock()
+		if msg.Type == LEAVE && msg.ClientId == c.Id {
+			fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Left the system", c.Id, c.Clock))
+			c.Lock.Unlock()
+			return
+		}
+
+		if msg.Type == MARKER {
+			c.handleMarker(msg)
+			c.Lock.Unlock()
+			continue
+		}
+
+		// Acknowledging every copy since the acknowledgement of an earlier copy might not have reached the server in time
+		go c.acknowledge(msg.Seq)
+
+		// The server recorded its state before sending the message, so the client has to record its own before receiving it
+		if msg.Snapshot > c.SnapshotId {
+			c.recordSnapshot(msg.Snapshot)
+		}
+
+		if c.isRetransmission(msg.Seq) {
+			fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Duplicate of message %d discarded: '%s'", c.Id, c.Clock, msg.Seq, msg.Message))
+			c.Lock.Unlock()
+			continue
+		}
+
+		// The client's own JOIN message initialises the clock, so it may be as far ahead as the server's clock is
+		welcome := msg.Type == JOIN && msg.ClientId == c.Id
+		if reason := c.Validation.Check(msg.Clock, c.Clock); reason != "" && !welcome {
+			fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Message from the server rejected, %s: '%s'", c.Id, c.Clock, reason, msg.Message))
+			c.Trace.Record(trace.Event{Node: c.Server, Type: trace.DROP, Peer: trace.Client(c.Id), MessageId: msg.MessageId, ClockBefore: msg.Clock, Clock: msg.Clock, Description: "rejected: " + reason})
+			c.closeChannel()
+			c.Schedule.Done(schedule.Step{Node: trace.Client(c.Id), Kind: schedule.RECEIVE, MessageId: msg.MessageId})
+			c.Lock.Unlock()
+			continue
+		}
+
+		clockBefore := c.Clock
 		c.Clock = max(c.Clock, msg.Clock) + 1 // updating the logical clock by finding the maximum between the two clock values
-		fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Message received from server: '%s'", c.Id, c.Clock, msg.Message))
+		c.Received += 1
+		if welcome {
+			// a late joiner catches up with the logical time of the system in a single step
+			fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Joined the system, clock initialised from the server's clock %d", c.Id, c.Clock, msg.Clock))
+			close(c.Joined)
+		} else if msg.Type == JOIN {
+			c.Peers = append(c.Peers, msg.ClientId)
+			fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Client %d joined the system, it can now be addressed", c.Id, c.Clock, msg.ClientId))
+		} else if msg.Type == LEAVE {
+			c.Peers = slices.DeleteFunc(c.Peers, func(id int) bool { return id == msg.ClientId })
+			fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Client %d left the system, it is no longer addressed", c.Id, c.Clock, msg.ClientId))
+		} else {
+			fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Message received from server for %s: '%s'", c.Id, c.Clock, msg.Recipients(), msg.Message))
+		}
+		c.Trace.Record(trace.Event{Node: trace.Client(c.Id), Type: trace.RECEIVE, Peer: c.Server, MessageId: msg.MessageId, ClockBefore: clockBefore, Clock: c.Clock, Description: msg.Message})
+		c.Schedule.Done(schedule.Step{Node: trace.Client(c.Id), Kind: schedule.RECEIVE, MessageId: msg.MessageId})
+		c.recordChannelMessage(msg)
+		c.Lock.Unlock()
+	}
+}
+
+// sends an acknowledgement for a message forwarded by the server.
+// Acknowledgements belong to the delivery layer, so they do not change the logical clock.
+func (c *Client) acknowledge(seq int) {
+	select {
+	case c.SendChannel <- Message{Type: ACK, ClientId: c.Id, Seq: seq}:
+	case <-c.Quit:
+	}
+}
+
+// makes the client leave the system, even once it has stopped sending
+func (c *Client) Leave() {
+	c.Lock.Lock()
+	close(c.Quit)
+	stopped := c.Stopped
+	c.Lock.Unlock()
+
+	if stopped {
+		c.sendLeave()
+	}
+}
+
+// function to stop sending once the client has sent its messages or the run is over. A leave asked for before
+// the client stopped is still sent here, and Leave sends the ones asked for after it
+func (c *Client) stopSending() {
+	c.Lock.Lock()
+	c.Stopped = true
+	quit := false
+	select {
+	case <-c.Quit:
+		quit = true
+	default:
+	}
+	c.Lock.Unlock()
+
+	if quit {
+		c.sendLeave()
+	}
+}
+
+// tells the server that the client is leaving the system
+func (c *Client) sendLeave() {
+	c.Lock.Lock()
+	clockBefore := c.Clock
+	c.Clock += 1
+	c.Sent += 1
+	message := Message{Type: LEAVE, Clock: c.Clock, ClientId: c.Id, Seq: c.Sent, MessageId: c.Trace.NewMessageId(trace.Client(c.Id)), Snapshot: c.SnapshotId}
+	fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Leaving the system", c.Id, c.Clock))
+	c.Trace.Record(trace.Event{Node: trace.Client(c.Id), Type: trace.SEND, Peer: c.Server, MessageId: message.MessageId, ClockBefore: clockBefore, Clock: c.Clock, Description: "leave"})
+	c.Lock.Unlock()
+
+	c.SendChannel <- message
+}
+
+// Checking if a message with this sequence number has already been received, recording it otherwise
+func (c *Client) isRetransmission(seq int) bool {
+	if c.hasReceived(seq) {
+		return true
+	}
+
+	c.SeqAhead[seq] = true
+	for c.SeqAhead[c.SeqReceived + 1] {
+		delete(c.SeqAhead, c.SeqReceived + 1)
+		c.SeqReceived += 1
 	}
+	return false
 }
@@ -1,7 +1,27 @@
 package client
 
+const (
+	MESSAGE = "MESSAGE" // Message of a client to every other client, or to the clients it is addressed to
+	ACK     = "ACK"     // Acknowledgement of a message forwarded by the server
+	MARKER  = "MARKER"  // Chandy–Lamport marker sent once the sender has recorded its state for a snapshot
+	JOIN    = "JOIN"    // First message of the server to a client that joined the system, carrying the server's clock, or announcing the join to the other clients
+	LEAVE   = "LEAVE"   // Client leaving the system, the server confirming that it has left, or announcing the leave to the other clients
+)
+
 type Message struct{
+	Type string // MESSAGE | ACK | MARKER | JOIN | LEAVE
 	Clock int
 	Message string
+	To []int // clients the message is addressed to, nil if it is broadcast or addressed to a topic
+	Topic string // topic the message is addressed to, empty if it is not
 	ClientId int
-}
\ No newline at end of file
+	Seq int // sequence number of the message on its link. A marker carries the last sequence number sent before it, a rumor its number at the peer that started it
+	MessageId string // identifies the send event of the message in the trace
+	Sender int // peer that pushed the message in the serverless mode, ClientId is the peer that started the rumor
+	Snapshot int // latest snapshot the sender had recorded its state for when it sent the message
+	RelayedBy string // server that relayed the message to this one in a federation, empty if it comes from its sender's server
+}
+
+func (m Message) IsEmpty() bool {
+	return m.Clock == 0 && m.Message == "" && m.ClientId == 0
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"lamports-clock/schedule"
+	"lamports-clock/trace"
+	"slices"
+)
+
+// returns the next message from the server. While a schedule is replayed, a message is held until handling it is the
+// client's next step, so the client handles the messages in the order of the recorded run whatever order they arrive in.
+// Duplicates are not steps, so they are handed over as soon as they arrive to be discarded
+func (c *Client) nextMessage() Message {
+	if !c.Schedule.Replaying() {
+		return <- c.ReceiveChannel
+	}
+
+	for{
+		advanced := c.Schedule.Advanced()
+		for i, msg := range c.Arrived{
+			c.Lock.Lock()
+			duplicate := c.hasReceived(msg.Seq)
+			c.Lock.Unlock()
+			if duplicate || c.Schedule.IsNext(schedule.Step{Node: trace.Client(c.Id), Kind: schedule.RECEIVE, MessageId: msg.MessageId}) {
+				c.Arrived = slices.Delete(c.Arrived, i, i + 1)
+				return msg
+			}
+		}
+
+		select {
+		case msg := <- c.ReceiveChannel:
+			c.Arrived = append(c.Arrived, msg)
+		case <-advanced:
+		}
+	}
+}
+
+// Checking if a message with this sequence number has already been received. Must be called with the lock held
+func (c *Client) hasReceived(seq int) bool {
+	return seq <= c.SeqReceived || c.SeqAhead[seq]
+}
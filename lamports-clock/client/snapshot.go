@@ -0,0 +1,89 @@
+package client
+
+import (
+	"fmt"
+	"lamports-clock/snapshot"
+	"lamports-clock/trace"
+	"slices"
+)
+
+// Recording of the channel from the server while a snapshot is being taken
+type ChannelRecording struct {
+	SnapshotId int
+	MarkerSeq int // every message the server forwarded before its marker has a sequence number up to this one
+	MarkerReceived bool
+}
+
+// starts a Chandy–Lamport snapshot of the whole system from this client
+func (c *Client) StartSnapshot() {
+	id, ok := c.Snapshots.Start(trace.Client(c.Id))
+	if !ok {
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Snapshot not started, another snapshot is still being taken", c.Id, c.Clock))
+		return
+	}
+
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Snapshot %d initiated", c.Id, c.Clock, id))
+	c.recordSnapshot(id)
+}
+
+// records the local state of the client for a snapshot, starts recording the channel from the server
+// and sends a marker to the server. Must be called with the lock held
+func (c *Client) recordSnapshot(id int) {
+	seqAhead := make([]int, 0, len(c.SeqAhead))
+	for seq := range c.SeqAhead{
+		seqAhead = append(seqAhead, seq)
+	}
+	slices.Sort(seqAhead)
+
+	c.SnapshotId = id
+	c.Snapshots.RecordState(id, snapshot.LocalState{Node: trace.Client(c.Id), Clock: c.Clock, Sent: c.Sent, Received: c.Received, SeqReceived: c.SeqReceived, SeqAhead: seqAhead})
+	c.Recording = &ChannelRecording{SnapshotId: id}
+	fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Local state recorded for snapshot %d, sending a marker to the server", c.Id, c.Clock, id))
+
+	// The marker tells the server how many messages were sent before it, since a message sent before
+	// the state was recorded might still be waiting to be put on the channel
+	go c.sendMarker(Message{Type: MARKER, ClientId: c.Id, Seq: c.Sent, Snapshot: id})
+}
+
+func (c *Client) sendMarker(marker Message) {
+	c.SendChannel <- marker
+}
+
+// handles a marker from the server. Must be called with the lock held
+func (c *Client) handleMarker(marker Message) {
+	if marker.Snapshot > c.SnapshotId {
+		c.recordSnapshot(marker.Snapshot)
+	}
+
+	if c.Recording != nil && c.Recording.SnapshotId == marker.Snapshot {
+		c.Recording.MarkerSeq = marker.Seq
+		c.Recording.MarkerReceived = true
+		c.closeChannel()
+	}
+}
+
+// adds a message that was sent by the server before it recorded its state, but received after the client
+// recorded its own, to the state of the channel. Must be called with the lock held
+func (c *Client) recordChannelMessage(msg Message) {
+	if c.Recording == nil || msg.Snapshot >= c.Recording.SnapshotId {
+		return
+	}
+
+	c.Snapshots.RecordMessage(c.Recording.SnapshotId, trace.SERVER, trace.Client(c.Id), snapshot.MessageRecord{Seq: msg.Seq, Clock: msg.Clock, ClientId: msg.ClientId, Message: msg.Message, MessageId: msg.MessageId})
+	fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Message %d was in transit from the server for snapshot %d: '%s'", c.Id, c.Clock, msg.Seq, c.Recording.SnapshotId, msg.Message))
+	c.closeChannel()
+}
+
+// stops recording the channel from the server once the marker and every message sent before it have arrived.
+// Messages are not delivered in order, so the marker alone does not close the channel. Must be called with the lock held
+func (c *Client) closeChannel() {
+	if c.Recording == nil || !c.Recording.MarkerReceived || c.SeqReceived < c.Recording.MarkerSeq {
+		return
+	}
+
+	c.Snapshots.CloseChannel(c.Recording.SnapshotId, trace.SERVER, trace.Client(c.Id))
+	fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Channel from the server recorded for snapshot %d", c.Id, c.Clock, c.Recording.SnapshotId))
+	c.Recording = nil
+}
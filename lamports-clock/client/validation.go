@@ -0,0 +1,51 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	FaultProbability = 0.2 // chance that a faulty client corrupts the clock of a message it sends
+	FaultJump = 1000000 // how far ahead a faulty client pushes the clock of a corrupted message
+)
+
+// Limits an incoming clock has to respect before it is merged into the local clock, nil if clocks are not validated
+type Validation struct {
+	MaxJump int // how far ahead of the local clock an incoming clock may be, 0 if the jump is not bounded
+	Quarantine time.Duration // how long the messages of a sender are rejected after one of its clocks was, 0 for the rest of the run
+}
+
+// Checking an incoming clock against the local clock, returns why it is rejected or "" if it is valid
+func (v *Validation) Check(clock int, local int) string {
+	if v == nil {
+		return ""
+	}
+	if clock < 0 {
+		return fmt.Sprintf("clock %d is negative", clock)
+	}
+	if v.MaxJump > 0 && clock - local > v.MaxJump {
+		return fmt.Sprintf("clock %d is %d ahead of the local clock %d, the limit is %d", clock, clock - local, local, v.MaxJump)
+	}
+	return ""
+}
+
+// Time until which a sender is quarantined when one of its clocks is rejected now, the zero time if it is for the rest of the run
+func (v *Validation) QuarantineUntil() time.Time {
+	if v == nil || v.Quarantine == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(v.Quarantine)
+}
+
+// corrupts the clock of a message the way a faulty or malicious client would, once in a while.
+// Only the message is corrupted, the client's own clock carries on as usual
+func (c *Client) corrupt(message *Message) {
+	if !c.Faulty || rand.Float64() >= FaultProbability {
+		return
+	}
+
+	message.Clock += FaultJump
+	fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Faulty client corrupting the clock of its message to %d", c.Id, c.Clock, message.Clock))
+}
@@ -0,0 +1,111 @@
+package clock
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Backends a Lamport clock shared by several goroutines can be kept in
+const (
+	MUTEX  = "mutex"  // an int guarded by a mutex
+	ATOMIC = "atomic" // an int updated with compare-and-swap, so no goroutine ever waits for another to update it
+)
+
+var Backends = []string{MUTEX, ATOMIC}
+
+// Lamport clock that several goroutines update at once
+type Lamport interface {
+	Load() int
+	Tick() (int, int) // increments the clock, returns its value before and after
+	Merge(received int) (int, int) // sets the clock to the maximum of its value and a received clock, plus one. Returns its value before and after
+	Store(value int) // replaces the clock, like a node that lost it in a crash or recovered it does
+	Contention() int // updates that had to wait for the update of another goroutine, or retry after it
+}
+
+func NewLamport(backend string) Lamport {
+	if backend == ATOMIC {
+		return &AtomicLamport{}
+	}
+	return &MutexLamport{}
+}
+
+// Lamport clock guarded by a mutex
+type MutexLamport struct {
+	Value int
+	Contended atomic.Int64 // lock acquisitions that found the lock held by another goroutine
+	Lock sync.Mutex
+}
+
+// takes the lock, counting the times it was held by another goroutine
+func (c *MutexLamport) lock() {
+	if !c.Lock.TryLock() {
+		c.Contended.Add(1)
+		c.Lock.Lock()
+	}
+}
+
+func (c *MutexLamport) Load() int {
+	c.lock()
+	defer c.Lock.Unlock()
+	return c.Value
+}
+
+func (c *MutexLamport) Tick() (int, int) {
+	c.lock()
+	defer c.Lock.Unlock()
+	c.Value += 1
+	return c.Value - 1, c.Value
+}
+
+func (c *MutexLamport) Merge(received int) (int, int) {
+	c.lock()
+	defer c.Lock.Unlock()
+	before := c.Value
+	c.Value = max(c.Value, received) + 1
+	return before, c.Value
+}
+
+func (c *MutexLamport) Store(value int) {
+	c.lock()
+	defer c.Lock.Unlock()
+	c.Value = value
+}
+
+func (c *MutexLamport) Contention() int {
+	return int(c.Contended.Load())
+}
+
+// Lamport clock updated with atomic operations. A tick is a single atomic add, a merge
+// retries its compare-and-swap until no other goroutine has updated the clock in the meantime
+type AtomicLamport struct {
+	Value atomic.Int64
+	Retries atomic.Int64 // compare-and-swaps that failed because another goroutine updated the clock first
+}
+
+func (c *AtomicLamport) Load() int {
+	return int(c.Value.Load())
+}
+
+func (c *AtomicLamport) Tick() (int, int) {
+	after := c.Value.Add(1)
+	return int(after - 1), int(after)
+}
+
+func (c *AtomicLamport) Merge(received int) (int, int) {
+	for{
+		before := c.Value.Load()
+		after := max(before, int64(received)) + 1
+		if c.Value.CompareAndSwap(before, after) {
+			return int(before), int(after)
+		}
+		c.Retries.Add(1)
+	}
+}
+
+func (c *AtomicLamport) Store(value int) {
+	c.Value.Store(int64(value))
+}
+
+func (c *AtomicLamport) Contention() int {
+	return int(c.Retries.Load())
+}
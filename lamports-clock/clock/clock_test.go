@@ -0,0 +1,39 @@
+package clock
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+// numbers of synthetic clients updating a clock at once
+var benchmarkClients = []int{10, 100, 1000, 5000}
+
+func BenchmarkLamportMutex(b *testing.B) {
+	benchmarkLamport(b, MUTEX)
+}
+
+func BenchmarkLamportAtomic(b *testing.B) {
+	benchmarkLamport(b, ATOMIC)
+}
+
+// updates a clock the way the server does for every message, merging the clock of the message and ticking to forward
+// it, from one goroutine per client. Reports the messages handled per second and the updates that had to wait for or
+// retry after the update of another goroutine
+func benchmarkLamport(b *testing.B, backend string) {
+	for _, clients := range benchmarkClients{
+		b.Run(fmt.Sprintf("clients=%d", clients), func(b *testing.B) {
+			shared := NewLamport(backend)
+			b.SetParallelism(max(1, clients / runtime.GOMAXPROCS(0)))
+			b.RunParallel(func(pb *testing.PB) {
+				received := 0
+				for pb.Next(){
+					_, received = shared.Merge(received)
+					shared.Tick()
+				}
+			})
+			b.ReportMetric(float64(b.N) / b.Elapsed().Seconds(), "msgs/sec")
+			b.ReportMetric(float64(shared.Contention()) / float64(b.N), "contended/msg")
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package gossip
+
+import (
+	"fmt"
+	"lamports-clock/client"
+	"lamports-clock/server"
+	"lamports-clock/trace"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	RoundInterval = 1 * time.Second // how often a peer pushes its active rumors
+)
+
+// Peer is a client of the serverless mode: it exchanges messages directly with random peers by push gossip,
+// keeping the same Lamport clock as a client of the server does. Every push and every copy received is an event.
+type Peer struct {
+	Id int
+	Inbox chan client.Message
+	Peers []chan client.Message // inboxes of every peer by id, including this one
+	Clock int
+	Fanout int // number of random peers a rumor is pushed to in every round
+	Rounds int // number of rounds a peer keeps pushing a rumor after it has learnt it
+	RumorInterval time.Duration // time between two rumors the peer starts, like the time between two messages of a client of the server
+	Network server.NetworkModel // decides how every push travels to its recipient
+	Seen map[string]bool // rumors already learnt, by origin and number
+	Active []*Rumor // rumors still being pushed
+	Sent int // rumors started by this peer
+	Rumors int // rumors the peer starts before it stops starting them, 0 if it keeps starting them until the run ends
+	Stop chan struct{} // closed to make the peer stop starting rumors while it keeps spreading the ones it knows, nil if it never does
+	Trace *trace.Recorder // records the peer's events, nil if tracing is disabled
+	Rng *rand.Rand // picks the peers to push to
+	Lock sync.Mutex
+}
+
+// rumor the peer keeps pushing for a number of rounds
+type Rumor struct {
+	Message client.Message
+	RoundsLeft int
+}
+
+// Creates the peers of a mesh of the given size, each of them able to reach every other one and starting a rumor every interval
+func NewMesh(size int, fanout int, rounds int, interval time.Duration, network server.NetworkModel, recorder *trace.Recorder, seed int64) []*Peer {
+	inboxes := make([]chan client.Message, size)
+	for i := range inboxes {
+		inboxes[i] = make(chan client.Message, size * fanout)
+	}
+
+	peers := make([]*Peer, size)
+	for i := range peers {
+		peers[i] = &Peer{
+			Id: i,
+			Inbox: inboxes[i],
+			Peers: inboxes,
+			Clock: 0, // every peer starts off with a logical clock of 0
+			Fanout: min(fanout, size - 1),
+			Rounds: rounds,
+			RumorInterval: interval,
+			Network: network,
+			Seen: make(map[string]bool),
+			Trace: recorder,
+			Rng: rand.New(rand.NewSource(seed + int64(i))),
+		}
+	}
+	return peers
+}
+
+// function to periodically start a new rumor, which is an internal event of the peer until it is pushed.
+// Returns once the peer has started its rumors or is stopped
+func (p *Peer) StartRumors() {
+	for{
+		select {
+		case <-p.Stop:
+			return
+		default:
+		}
+
+		p.Lock.Lock()
+		p.Sent += 1
+		clockBefore := p.Clock
+		p.Clock += 1
+		message := client.Message{Type: client.MESSAGE, Message: fmt.Sprintf("Hello from client %d", p.Id), ClientId: p.Id, Seq: p.Sent}
+		p.Trace.Record(trace.Event{Node: trace.Client(p.Id), Type: trace.INTERNAL, ClockBefore: clockBefore, Clock: p.Clock, Description: fmt.Sprintf("start rumor %d", message.Seq)})
+		p.Seen[rumorId(message)] = true
+		p.Active = append(p.Active, &Rumor{Message: message, RoundsLeft: p.Rounds})
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Starting rumor %d: '%s'", p.Id, p.Clock, message.Seq, message.Message))
+		p.Lock.Unlock()
+		if message.Seq == p.Rumors {
+			return
+		}
+
+		select {
+		case <-p.Stop:
+		case <-time.After(p.RumorInterval):
+		}
+	}
+}
+
+// function to push every active rumor to random peers once per round
+func (p *Peer) PushRumors() {
+	for{
+		time.Sleep(RoundInterval)
+
+		p.Lock.Lock()
+		pushes := make(map[int][]client.Message)
+		active := make([]*Rumor, 0, len(p.Active))
+		for _, rumor := range p.Active{
+			for _, peerId := range p.pickPeers(){
+				clockBefore := p.Clock
+				p.Clock += 1
+				message := rumor.Message
+				message.Clock = p.Clock
+				message.Sender = p.Id
+				message.MessageId = p.Trace.NewMessageId(trace.Client(p.Id))
+				p.Trace.Record(trace.Event{Node: trace.Client(p.Id), Type: trace.SEND, Peer: trace.Client(peerId), MessageId: message.MessageId, ClockBefore: clockBefore, Clock: p.Clock, Description: message.Message})
+				pushes[peerId] = append(pushes[peerId], message)
+			}
+			rumor.RoundsLeft -= 1
+			if rumor.RoundsLeft > 0 {
+				active = append(active, rumor)
+			}
+		}
+		p.Active = active
+		p.Lock.Unlock()
+
+		for peerId, messages := range pushes{
+			for _, message := range messages{
+				p.transmit(peerId, message)
+			}
+		}
+	}
+}
+
+// function to send a push over the link to a peer, which may drop, delay or duplicate it.
+// Gossip does not retransmit, the other pushes of the rumor make up for the ones that are lost.
+func (p *Peer) transmit(peerId int, message client.Message) {
+	transmission := p.Network.Transmit(peerId)
+	if transmission.Dropped {
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Pushing rumor %d of client %d to client %d is dropped", p.Id, message.Clock, message.Seq, message.ClientId, peerId))
+		p.Trace.Record(trace.Event{Node: trace.Client(p.Id), Type: trace.DROP, Peer: trace.Client(peerId), MessageId: message.MessageId, ClockBefore: message.Clock, Clock: message.Clock, Description: message.Message})
+		return
+	}
+
+	inbox := p.Peers[peerId]
+	for _, delay := range transmission.Delays{
+		go func() {
+			time.Sleep(delay)
+			inbox <- message
+		}()
+	}
+	fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Rumor %d of client %d pushed to client %d: '%s'", p.Id, message.Clock, message.Seq, message.ClientId, peerId, message.Message))
+}
+
+// function to receive the pushes of other peers. A rumor the peer has not seen yet is delivered
+// and pushed on for a number of rounds, a rumor it has already seen only updates the clock.
+func (p *Peer) ReceiveMessages() {
+	for{
+		msg := <- p.Inbox
+
+		p.Lock.Lock()
+		clockBefore := p.Clock
+		p.Clock = max(p.Clock, msg.Clock) + 1 // updating the logical clock by finding the maximum between the two clock values
+		p.Trace.Record(trace.Event{Node: trace.Client(p.Id), Type: trace.RECEIVE, Peer: trace.Client(msg.Sender), MessageId: msg.MessageId, ClockBefore: clockBefore, Clock: p.Clock, Description: msg.Message})
+
+		if p.Seen[rumorId(msg)] {
+			fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Rumor %d of client %d already known: '%s'", p.Id, p.Clock, msg.Seq, msg.ClientId, msg.Message))
+		} else {
+			p.Seen[rumorId(msg)] = true
+			p.Active = append(p.Active, &Rumor{Message: msg, RoundsLeft: p.Rounds})
+			fmt.Println(fmt.Sprintf("[CLIENT-%d-LC%d] Rumor %d of client %d received: '%s'", p.Id, p.Clock, msg.Seq, msg.ClientId, msg.Message))
+		}
+		p.Lock.Unlock()
+	}
+}
+
+// number of rumors the peer still pushes
+func (p *Peer) Spreading() int {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	return len(p.Active)
+}
+
+// picking Fanout distinct peers other than this one at random. Must be called with the lock held
+func (p *Peer) pickPeers() []int {
+	picked := make([]int, 0, p.Fanout)
+	for _, id := range p.Rng.Perm(len(p.Peers)){
+		if len(picked) == p.Fanout {
+			break
+		}
+		if id != p.Id {
+			picked = append(picked, id)
+		}
+	}
+	return picked
+}
+
+// rumors are identified by the peer that started them and their number at that peer
+func rumorId(message client.Message) string {
+	return fmt.Sprintf("%d-%d", message.ClientId, message.Seq)
+}
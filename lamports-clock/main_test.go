@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// the test binary runs the demo instead of the tests when it is started by runDemo
+func TestMain(m *testing.M) {
+	if os.Getenv("RUN_DEMO") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runs the demo with the given flags in a child process, since it parses the command line and exits on bad flags
+func runDemo(t *testing.T, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "RUN_DEMO=1")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("run with %v failed: %v\n%s", args, err, output)
+	}
+	return string(output)
+}
+
+// a bounded run ends on its own once every client has sent its messages and the server has had every forward
+// acknowledged, and the summary accounts for every message
+func TestBoundedStarRun(t *testing.T) {
+	clients, messages := 4, 3
+	output := runDemo(t, "-clients", strconv.Itoa(clients), "-messages", strconv.Itoa(messages), "-seed", "7", "-send-interval", "20ms", "-drop-rate", "0.2")
+
+	for _, line := range []string{"[RUN] Every client has stopped sending messages", "[RUN] Every message has been acknowledged"} {
+		if !strings.Contains(output, line) {
+			t.Fatalf("output is missing %q:\n%s", line, output)
+		}
+	}
+	for i := range clients {
+		line := fmt.Sprintf("[SUMMARY] client-%d: %d sent,", i, messages)
+		if !strings.Contains(output, line) {
+			t.Errorf("output is missing %q", line)
+		}
+	}
+
+	total := regexp.MustCompile(`\[SUMMARY\] (\d+) messages sent, (\d+) dropped, (\d+) delivered`).FindStringSubmatch(output)
+	if total == nil {
+		t.Fatalf("output has no summary of the messages:\n%s", output)
+	}
+	sent, _ := strconv.Atoi(total[1])
+	delivered, _ := strconv.Atoi(total[3])
+	// every message of a client is forwarded to every other client
+	if sent < clients * messages * clients || delivered != sent {
+		t.Errorf("summary has %d messages sent and %d delivered, want every one of at least %d delivered", sent, delivered, clients * messages * clients)
+	}
+}
+
+// a bounded run of the mesh ends once every peer has started its rumors and no peer has a rumor left to push
+func TestBoundedMeshRun(t *testing.T) {
+	peers, rumors := 4, 2
+	output := runDemo(t, "-topology", "mesh", "-clients", strconv.Itoa(peers), "-messages", strconv.Itoa(rumors), "-seed", "7", "-send-interval", "20ms", "-drop-rate", "0.2")
+
+	for _, line := range []string{"[RUN] Every client has stopped sending messages", "[RUN] Every rumor has stopped spreading"} {
+		if !strings.Contains(output, line) {
+			t.Fatalf("output is missing %q:\n%s", line, output)
+		}
+	}
+	if started := strings.Count(output, "Starting rumor"); started != peers * rumors {
+		t.Errorf("%d rumors started, want %d", started, peers * rumors)
+	}
+}
+
+// churn in a run bounded by a number of messages stops after as many joins and leaves, and the clients that leave
+// after they have sent their messages still tell the server
+func TestBoundedChurnRun(t *testing.T) {
+	clients, messages := 3, 6
+	output := runDemo(t, "-clients", strconv.Itoa(clients), "-messages", strconv.Itoa(messages), "-churn", "20ms", "-seed", "7", "-send-interval", "40ms", "-drop-rate", "0.2")
+
+	for _, line := range []string{"[RUN] Every client has stopped sending messages", "[RUN] Every message has been acknowledged"} {
+		if !strings.Contains(output, line) {
+			t.Fatalf("output is missing %q:\n%s", line, output)
+		}
+	}
+	joined := len(regexp.MustCompile(`(?m)^\[SERVER-.*\] Client \d+ joined the system`).FindAllString(output, -1))
+	left := len(regexp.MustCompile(`(?m)^\[SERVER-.*\] Client \d+ left the system`).FindAllString(output, -1))
+	if joined - clients + left != messages {
+		t.Errorf("%d clients joined and %d left, want %d joins and leaves after the %d starting clients", joined, left, messages, clients)
+	}
+	if leaving := strings.Count(output, "Leaving the system"); leaving != left {
+		t.Errorf("%d clients left but the server saw %d leaves", leaving, left)
+	}
+}
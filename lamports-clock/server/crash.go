@@ -0,0 +1,198 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"lamports-clock/client"
+	"lamports-clock/trace"
+	"os"
+	"time"
+)
+
+// How the server recovers its clock when it restarts after a crash
+const (
+	CHECKPOINT = "checkpoint" // from the last checkpoint it wrote to a file
+	MAX        = "max"        // from the largest clock in the next message of every client
+)
+
+var RecoveryModes = []string{CHECKPOINT, MAX}
+
+const (
+	RecoveryTimeout = 10 * time.Second // how long the server waits for the next message of every client when it recovers its clock from them
+)
+
+// clock of the server persisted to a file so it survives a crash
+type Checkpoint struct {
+	Clock int `json:"clock"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// function to crash the server: its clock and the messages it has not delivered yet are lost, and it stops
+// handling the clients' messages until it restarts. The clients, their sequence numbers and the welcomes
+// and membership announcements they have not acknowledged yet survive, like a broker's durable membership.
+func (s *Server) Crash() {
+	s.Steps.Lock()
+	defer s.Steps.Unlock()
+	s.Lock.Lock()
+	if s.Down || s.Recovering {
+		s.Lock.Unlock()
+		return
+	}
+
+	s.Down = true
+	s.Crashes += 1
+	s.Restarted = make(chan struct{})
+	s.ClockBeforeCrash = s.Clock.Load()
+	s.Clock.Store(0)
+	lost := 0
+	for i, messages := range s.Pending{
+		for seq, pending := range messages{
+			if pending.Message.Type != client.JOIN && pending.Message.Type != client.LEAVE {
+				delete(messages, seq)
+				lost += 1
+			}
+		}
+		for _, message := range s.Queues[i].Clear(){
+			s.Trace.Record(trace.Event{Node: s.Node, Type: trace.DROP, Peer: trace.Client(i), MessageId: message.MessageId, ClockBefore: message.Clock, Clock: message.Clock, Description: "lost in crash"})
+		}
+	}
+	s.Lock.Unlock()
+
+	fmt.Println(fmt.Sprintf("[SERVER-LC%d] Crashed, %d messages not acknowledged yet are lost", s.ClockBeforeCrash, lost))
+}
+
+// function to restart the server after a crash, recovering its clock from the checkpoint
+// or waiting for the next message of every client to recover it from theirs
+func (s *Server) Restart() {
+	s.Steps.Lock()
+	defer s.Steps.Unlock()
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	if !s.Down {
+		return
+	}
+	s.Down = false
+
+	if s.Recovery == CHECKPOINT {
+		checkpoint, err := s.readCheckpoint()
+		if err != nil {
+			fmt.Println("[SERVER] No checkpoint to recover the clock from, starting from 0: ", err)
+		}
+		s.Clock.Store(checkpoint.Clock)
+		s.finishRecovery(fmt.Sprintf("clock %d recovered from the checkpoint of %s", checkpoint.Clock, checkpoint.SavedAt.Format(time.TimeOnly)))
+		return
+	}
+
+	s.Recovering = true
+	close(s.Restarted) // waking up the messages that arrived while the server was down so they take part in the recovery
+	s.Restarted = make(chan struct{})
+	s.Awaiting = make(map[int]bool)
+	for id := range s.SendChannels{
+		if !s.isQuarantined(id) {
+			s.Awaiting[id] = true // the messages of a quarantined client are rejected before they could take part
+		}
+	}
+	fmt.Println(fmt.Sprintf("[SERVER] Restarted, waiting for the next message of %d clients to recover the clock", len(s.Awaiting)))
+	if len(s.Awaiting) == 0 {
+		s.finishRecovery("no client to recover the clock from")
+		return
+	}
+
+	crashes := s.Crashes
+	go func() {
+		time.Sleep(RecoveryTimeout)
+		s.Steps.Lock()
+		defer s.Steps.Unlock()
+		s.Lock.Lock()
+		defer s.Lock.Unlock()
+		if s.Recovering && s.Crashes == crashes {
+			s.finishRecovery(fmt.Sprintf("clock %d recovered from the clients that sent a message within %v", s.Clock.Load(), RecoveryTimeout))
+		}
+	}()
+}
+
+// function to start a step and take the lock once the server is running. While the server recovers its clock from
+// the clients, the clock of the message is taken into account and the message waits until the recovery is over.
+// Returns with the lock held, and the function that ends the step to call once it is released
+func (s *Server) lockRunning(clientId int, msg client.Message) func() {
+	for{
+		end := s.beginStep()
+		s.Lock.Lock()
+		if !s.Down && !s.Recovering {
+			return end
+		}
+		s.Lock.Unlock()
+		end()
+
+		// the recovery replaces the clock, so no other step may update it in the meantime
+		s.Steps.Lock()
+		s.Lock.Lock()
+		if s.Recovering && s.Awaiting[clientId] {
+			s.Clock.Store(max(s.Clock.Load(), msg.Clock))
+			delete(s.Awaiting, clientId)
+			if len(s.Awaiting) == 0 {
+				s.finishRecovery(fmt.Sprintf("clock %d recovered from the next message of every client", s.Clock.Load()))
+			}
+		}
+		restarted := s.Restarted
+		running := !s.Down && !s.Recovering
+		s.Lock.Unlock()
+		s.Steps.Unlock()
+		if !running {
+			<-restarted
+		}
+	}
+}
+
+// function to resume after the clock has been recovered. The restart is an event of the server whose clock
+// continues from the clock before the crash in the trace, so a recovered clock that went back shows up as a
+// violation of monotonicity. Must be called with Steps held for writing and the lock held
+func (s *Server) finishRecovery(detail string) {
+	_, currentClock := s.Clock.Tick()
+	s.Recovering = false
+	close(s.Restarted)
+	if currentClock <= s.ClockBeforeCrash {
+		fmt.Println(fmt.Sprintf("[SERVER-LC%d] Restarted, %s. The clock was %d before the crash, so the clock condition may not hold", currentClock, detail, s.ClockBeforeCrash))
+	} else {
+		fmt.Println(fmt.Sprintf("[SERVER-LC%d] Restarted, %s", currentClock, detail))
+	}
+	s.Trace.Record(trace.Event{Node: s.Node, Type: trace.INTERNAL, ClockBefore: s.ClockBeforeCrash, Clock: currentClock, Description: "restart"})
+}
+
+// function to periodically write the server's clock to the checkpoint file while the server is running,
+// if the clock is recovered from checkpoints
+func (s *Server) WriteCheckpoints(interval time.Duration) {
+	if s.Recovery != CHECKPOINT {
+		return
+	}
+
+	for{
+		time.Sleep(interval)
+
+		s.Lock.Lock()
+		if !s.Down && !s.Recovering {
+			if err := s.writeCheckpoint(); err != nil {
+				fmt.Println("Error occurred while writing the checkpoint: ", err)
+			}
+		}
+		s.Lock.Unlock()
+	}
+}
+
+// Must be called with the lock held
+func (s *Server) writeCheckpoint() error {
+	data, err := json.Marshal(Checkpoint{Clock: s.Clock.Load(), SavedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.CheckpointFile, data, 0644)
+}
+
+func (s *Server) readCheckpoint() (Checkpoint, error) {
+	var checkpoint Checkpoint
+	data, err := os.ReadFile(s.CheckpointFile)
+	if err != nil {
+		return checkpoint, err
+	}
+	return checkpoint, json.Unmarshal(data, &checkpoint)
+}
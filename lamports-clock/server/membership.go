@@ -0,0 +1,93 @@
+package server
+
+import (
+	"fmt"
+	"lamports-clock/client"
+	"lamports-clock/trace"
+)
+
+// function to add a new client to the system while it is running. The server welcomes the client
+// with a JOIN message carrying its clock, which the client initialises its own clock from,
+// and announces it to the other clients so they can address their messages to it.
+// The returned client still has to be started by the caller.
+func (s *Server) Join() *client.Client {
+	end := s.lockRunning(-1, client.Message{}) // a crashed server cannot welcome new clients until it has restarted
+	recipients := s.clientIds()
+	id := s.NextId
+	s.NextId += 1
+
+	sendChannel := make(chan client.Message) // channel for the server to send messages to the client
+	receiveChannel := make(chan client.Message) // channel for the client to send messages to the server
+	s.SendChannels[id] = sendChannel
+	s.ReceiveChannels[id] = receiveChannel
+	s.Departed[id] = make(chan struct{})
+	s.Pending[id] = make(map[int]*PendingMessage)
+
+	// every client gets its own queue and sender goroutine so a slow client only holds up its own messages
+	s.Queues[id] = NewOutboundQueue(s.QueueCapacity, s.OverflowPolicy)
+	go s.sendQueued(s.Queues[id], sendChannel, s.Departed[id])
+	fmt.Println(fmt.Sprintf("[SERVER-LC%d] Client %d joined the system", s.Clock.Load(), id))
+	s.Lock.Unlock()
+	end()
+
+	go s.handleClientChannels(id, receiveChannel)
+	s.forward(id, client.Message{Type: client.JOIN, Message: fmt.Sprintf("Welcome client %d", id), ClientId: id})
+	// The other clients may not be receiving yet while the system starts up, so the join is announced in the background
+	// rather than waiting for room in their queues
+	go func() {
+		for _, i := range recipients{
+			if s.forward(i, client.Message{Type: client.JOIN, Message: fmt.Sprintf("client %d joined", id), ClientId: id}) {
+				fmt.Println(fmt.Sprintf("[SERVER-LC%d] Client %d has been notified that client %d joined", s.Clock.Load(), i, id))
+			}
+		}
+	}()
+
+	return &client.Client{
+		Id: id,
+		Server: s.Node,
+		SendChannel: receiveChannel,
+		ReceiveChannel: sendChannel,
+		Clock: 0, // the clock is initialised from the JOIN message before the client sends anything
+		SeqAhead: make(map[int]bool),
+		Trace: s.Trace,
+		Schedule: s.Schedule,
+		Snapshots: s.Snapshots,
+		Joined: make(chan struct{}),
+		Quit: make(chan struct{}),
+	}
+}
+
+// function to remove a client from the system while it is running.
+// Called once the client's LEAVE message has been received, after every message before it has been handled.
+func (s *Server) Leave(clientId int) {
+	end := s.beginStep()
+	s.Lock.Lock()
+	channel, departed, queue := s.SendChannels[clientId], s.Departed[clientId], s.Queues[clientId]
+	delete(s.SendChannels, clientId)
+	delete(s.ReceiveChannels, clientId)
+	delete(s.Departed, clientId)
+	delete(s.Queues, clientId)
+	delete(s.Pending, clientId)
+	delete(s.NextSeq, clientId)
+	delete(s.SeqReceived, clientId)
+	s.unsubscribe(clientId)
+	recipients := s.clientIds()
+	s.Lock.Unlock()
+	clockBefore, currentClock := s.Clock.Tick()
+	s.Trace.Record(trace.Event{Node: s.Node, Type: trace.INTERNAL, ClockBefore: clockBefore, Clock: currentClock, Description: fmt.Sprintf("client %d left", clientId)})
+	end()
+
+	fmt.Println(fmt.Sprintf("[SERVER-LC%d] Client %d left the system", currentClock, clientId))
+
+	// Confirming the leave so the client stops receiving, then abandoning any forwards still waiting on it
+	channel <- client.Message{Type: client.LEAVE, Clock: currentClock, ClientId: clientId}
+	close(departed)
+	queue.Close()
+
+	// Announcing the leave so the other clients stop addressing their messages to it
+	for _, i := range recipients{
+		if s.forward(i, client.Message{Type: client.LEAVE, Message: fmt.Sprintf("client %d left", clientId), ClientId: clientId}) {
+			fmt.Println(fmt.Sprintf("[SERVER-LC%d] Client %d has been notified that client %d left", s.Clock.Load(), i, clientId))
+		}
+	}
+}
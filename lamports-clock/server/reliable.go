@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"lamports-clock/client"
+	"slices"
+	"time"
+)
+
+const (
+	AckTimeout = 2 * time.Second // how long the server waits for an acknowledgement before retransmitting
+	RetransmitInterval = 500 * time.Millisecond // how often the server looks for messages to retransmit
+)
+
+// forwarded message waiting for an acknowledgement from its recipient
+type PendingMessage struct {
+	Message client.Message
+	SentAt time.Time
+	Attempts int
+	Dropped bool // the network dropped the last transmission, so the message is not in the channel until it is retransmitted
+}
+
+// function to retransmit every forwarded message that has not been acknowledged in time.
+// A retransmission carries the clock of the original forward since it is the same send event
+// as far as the logical clock is concerned, so it does not increment the server's clock.
+func (s *Server) RetransmitMessages(){
+	for{
+		time.Sleep(RetransmitInterval)
+
+		s.Lock.Lock()
+		expired := make(map[int][]PendingMessage)
+		for i, messages := range s.Pending{
+			for _, pending := range messages{
+				if time.Since(pending.SentAt) >= AckTimeout {
+					pending.SentAt = time.Now()
+					pending.Attempts += 1
+					expired[i] = append(expired[i], *pending)
+				}
+			}
+		}
+		s.Lock.Unlock()
+
+		for i, messages := range expired{
+			slices.SortFunc(messages, func(a, b PendingMessage) int { return a.Message.Seq - b.Message.Seq })
+			for _, pending := range messages{
+				message := pending.Message
+				fmt.Println(fmt.Sprintf("[SERVER-LC%d] No acknowledgement from client %d for message %d, retransmitting: '%s'", message.Clock, i, message.Seq, message.Message))
+				s.transmit(i, message, pending.Attempts)
+			}
+		}
+	}
+}
+
+// function to stop retransmitting a message once its recipient has acknowledged it
+func (s *Server) acknowledge(clientId int, seq int){
+	s.Lock.Lock()
+	pending, ok := s.Pending[clientId][seq]
+	delete(s.Pending[clientId], seq)
+	s.Lock.Unlock()
+
+	if ok && pending.Attempts > 1 {
+		fmt.Println(fmt.Sprintf("[SERVER-LC%d] Message %d acknowledged by client %d after %d transmissions", pending.Message.Clock, seq, clientId, pending.Attempts))
+	}
+}
+
+// function to count the forwarded messages still waiting for an acknowledgement, to tell when a run has settled
+func (s *Server) Unacknowledged() int {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	count := 0
+	for _, messages := range s.Pending{
+		count += len(messages)
+	}
+	return count
+}
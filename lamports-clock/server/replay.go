@@ -0,0 +1,24 @@
+package server
+
+import (
+	"lamports-clock/client"
+	"lamports-clock/schedule"
+	"lamports-clock/trace"
+)
+
+// function to ask the network model what happens to a transmission, or to take the decision it took in the
+// recorded run when a schedule is replayed. A retransmission the recorded run did not make is left to the network model
+func (s *Server) decide(clientId int, message client.Message, attempt int) Transmission {
+	step := schedule.Step{Node: s.Node, Kind: schedule.TRANSMIT, Peer: trace.Client(clientId), Seq: message.Seq, Attempt: attempt}
+
+	var transmission Transmission
+	if decision, ok := s.Schedule.Decision(step); ok {
+		transmission = Transmission{Dropped: decision.Dropped, Delays: decision.Delays}
+	} else {
+		transmission = s.Network.Transmit(clientId)
+	}
+
+	step.Dropped, step.Delays = transmission.Dropped, transmission.Delays
+	s.Schedule.Done(step)
+	return transmission
+}
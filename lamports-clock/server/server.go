@@ -3,70 +3,282 @@ package server
 import (
 	"fmt"
 	"lamports-clock/client"
-	"math/rand"
+	"lamports-clock/clock"
+	"lamports-clock/schedule"
+	"lamports-clock/snapshot"
+	"lamports-clock/trace"
+	"slices"
 	"sync"
 	"time"
 )
 
 type Server struct {
-	Clock int
-	SendChannels []chan client.Message
-	ReceiveChannels []chan client.Message
+	Node string // name of the server in the trace
+	Clock clock.Lamport // kept in a mutex or in an atomic, and updated outside Lock so only the backend serializes the updates
+	SendChannels map[int]chan client.Message // channels for the server to send messages to the clients, by client
+	ReceiveChannels map[int]chan client.Message // channels for the clients to send messages to the server, by client
+	Departed map[int]chan struct{} // closed when a client leaves so forwards still waiting on it are abandoned
+	Queues map[int]*OutboundQueue // messages waiting to be put on each client's channel
+	NextId int // id given to the next client that joins
+	QueueCapacity int // number of messages each queue holds before its overflow policy applies
+	OverflowPolicy string // what a full queue does with the next message: BLOCK | DROP_OLDEST | DROP_NEWEST
+	Network NetworkModel // decides how every forwarded message travels to its recipient
+	NextSeq map[int]int // last sequence number used on the link to each client
+	Pending map[int]map[int]*PendingMessage // forwarded messages not acknowledged yet, by client and sequence number
+	Trace *trace.Recorder // records the server's events, nil if tracing is disabled
+	Schedule *schedule.Schedule // records the order of the server's steps or replays a recorded one, nil if it does neither
+	Sent int // messages forwarded to the clients, not counting retransmissions
+	Received int // messages received from the clients
+	SeqReceived map[int]int // highest sequence number received from each client
+	SnapshotId int // latest snapshot the server has recorded its state for
+	Recording map[int]*ChannelRecording // recordings of the channels from the clients, by client
+	Snapshots *snapshot.Collector // assembles the snapshots, nil if snapshots are disabled
+	Topics map[string]map[int]bool // clients subscribed to each topic
+	Recovery string // how the clock is recovered after a crash: CHECKPOINT | MAX
+	CheckpointFile string // file the clock is checkpointed to when it is recovered from checkpoints
+	Down bool // the server has crashed and not restarted yet
+	Recovering bool // the server has restarted and waits for the next message of every client to recover its clock
+	Awaiting map[int]bool // clients whose next message the server still waits for to recover its clock
+	Restarted chan struct{} // closed when the server restarts after a crash, and again once it has recovered its clock from the clients
+	Crashes int // number of times the server has crashed
+	ClockBeforeCrash int // clock the server had when it last crashed, kept for the trace and the logs only
+	Validation *client.Validation // limits the clocks received from the clients have to respect, nil if they are not validated
+	Quarantined map[int]time.Time // clients whose messages are rejected, with the time their quarantine is over or the zero time if it never is
+	Rejected int // messages rejected because of their clock or their sender's quarantine
+	Links []*Link // links to the neighbouring servers of a federation
+	Relayed chan client.Message // messages relayed by the neighbouring servers of a federation
+	Remote map[int]bool // ids of the clients of the other regions of a federation
+	Steps sync.RWMutex // held by the steps that update the clock, see beginStep. Always taken before Lock
 	Lock sync.Mutex
 }
 
-// function to receive messages from client
-func (s *Server) ReceiveMessage(){
-	for i := range s.ReceiveChannels{
-		go s.handleClientChannels(i)
+// function to start a step that updates the clock, returning the function that ends it. The steps of a server
+// run at once and only the clock backend orders their updates, unless the run is traced, scheduled or snapshotted:
+// those need every step to see the clock the previous one left, so the steps run one at a time. A crash or a
+// recovery replaces the clock, so it waits for the steps in progress by taking Steps for writing
+func (s *Server) beginStep() func() {
+	if s.Trace != nil || s.Schedule != nil || s.Snapshots != nil {
+		s.Steps.Lock()
+		return s.Steps.Unlock
 	}
+	s.Steps.RLock()
+	return s.Steps.RUnlock
 }
 
 // function to handle all client channels
-func (s *Server) handleClientChannels(clientId int){
+func (s *Server) handleClientChannels(clientId int, channel chan client.Message){
 	for{
-		msg := <- s.ReceiveChannels[clientId]
-		
-		s.Lock.Lock()
-		s.Clock = max(s.Clock, msg.Clock) + 1
-		fmt.Println(fmt.Sprintf("[SERVER-LC%d] Message receieved: '%s'", s.Clock, msg.Message))
+		msg := <- channel
+
+		if msg.Type == client.ACK {
+			s.acknowledge(clientId, msg.Seq)
+			continue
+		}
+
+		if msg.Type == client.MARKER {
+			end := s.beginStep()
+			s.Lock.Lock()
+			markers := s.handleMarker(clientId, msg)
+			s.Lock.Unlock()
+			end()
+			s.sendMarkers(markers)
+			continue
+		}
+
+		if !s.validate(clientId, msg) {
+			if msg.Type == client.LEAVE {
+				// a client still leaves when its clock is rejected, the clock just isn't merged
+				s.Leave(clientId)
+				return
+			}
+			continue
+		}
+
+		step := s.Schedule.Await(schedule.Step{Node: s.Node, Kind: schedule.RECEIVE, MessageId: msg.MessageId})
+		// A crashed server only handles the message once it has restarted
+		end := s.lockRunning(clientId, msg)
+		// The client recorded its state before sending the message, so the server has to record its own before receiving it
+		var markers map[int]client.Message
+		if msg.Snapshot > s.SnapshotId {
+			markers = s.recordSnapshot(msg.Snapshot)
+		}
+		s.Received += 1
+		s.SeqReceived[clientId] = max(s.SeqReceived[clientId], msg.Seq)
+		s.recordChannelMessage(clientId, msg)
 		s.Lock.Unlock()
 
-		if msg != (client.Message{}) {
-			// send to all clients which don't have id as clientId
+		clockBefore, currentClock := s.Clock.Merge(msg.Clock)
+		description := msg.Message
+		if msg.Type == client.LEAVE {
+			description = "leave"
+			fmt.Println(fmt.Sprintf("[SERVER-LC%d] Leave request receieved from client %d", currentClock, clientId))
+		} else {
+			fmt.Println(fmt.Sprintf("[SERVER-LC%d] Message receieved for %s: '%s'", currentClock, msg.Recipients(), msg.Message))
+		}
+		s.Trace.Record(trace.Event{Node: s.Node, Type: trace.RECEIVE, Peer: trace.Client(clientId), MessageId: msg.MessageId, ClockBefore: clockBefore, Clock: currentClock, Description: description})
+		s.Schedule.Done(step)
+		end()
+		s.sendMarkers(markers)
+
+		if msg.Type == client.LEAVE {
+			s.Leave(clientId)
+			return
+		}
+		if !msg.IsEmpty() {
+			// send to the clients the message is routed to, never back to its sender
 			s.sendMessage(msg)
 		}
 	}
 }
 
-// function to send message to clients except the one who sent the message
+// function to send message to the clients it is routed to
 func (s *Server) sendMessage(message client.Message){
-	
-	if !s.coinFlip(){
-		s.Lock.Lock()
-		s.Clock += 1
-		currentClock := s.Clock
+	s.relay(message)
+
+	s.Lock.Lock()
+	recipients := s.recipients(message)
+	s.Lock.Unlock()
+
+	for _, i := range recipients{
+		s.forward(i, client.Message{Type: client.MESSAGE, Message: message.Message, To: message.To, Topic: message.Topic, ClientId: message.ClientId, MessageId: message.MessageId})
+	}
+}
+
+// function to forward a message to one client with the current server clock, returns false if the client has left.
+// The message carries the id of the message it passes on until it gets its own
+func (s *Server) forward(clientId int, message client.Message) bool {
+	step := s.Schedule.Await(schedule.Step{Node: s.Node, Kind: schedule.FORWARD, Peer: trace.Client(clientId), MessageId: message.MessageId})
+	end := s.beginStep()
+	s.Lock.Lock()
+	pending, ok := s.Pending[clientId]
+	if !ok || s.Down {
+		// the client left in the meantime, or the server crashed and lost the message
 		s.Lock.Unlock()
-		fmt.Println(fmt.Sprintf("[SERVER-LC%d] Forwarding the message of client %d is dropped", currentClock, message.ClientId))
+		end()
+		return false
+	}
+	s.NextSeq[clientId] += 1
+	s.Sent += 1
+	message.Seq = s.NextSeq[clientId]
+	message.Snapshot = s.SnapshotId
+	s.Lock.Unlock()
+
+	clockBefore, currentClock := s.Clock.Tick()
+	message.Clock = currentClock
+	message.MessageId = s.Trace.NewMessageId(s.Node)
+	s.Trace.Record(trace.Event{Node: s.Node, Type: trace.SEND, Peer: trace.Client(clientId), MessageId: message.MessageId, ClockBefore: clockBefore, Clock: currentClock, Description: message.Message})
+	s.Schedule.Done(step)
+
+	s.Lock.Lock()
+	pending[message.Seq] = &PendingMessage{Message: message, SentAt: time.Now(), Attempts: 1}
+	s.Lock.Unlock()
+	end()
+
+	s.transmit(clientId, message, 1)
+	return true
+}
+
+// function to send a message over the link to a client, which may drop, delay or duplicate it
+func (s *Server) transmit(clientId int, message client.Message, attempt int){
+	// the network model decides whether the message reaches the client, how long it takes and how many copies arrive
+	transmission := s.decide(clientId, message, attempt)
+
+	s.Lock.Lock()
+	if pending, ok := s.Pending[clientId][message.Seq]; ok {
+		pending.Dropped = transmission.Dropped
+	}
+	s.Lock.Unlock()
+
+	if transmission.Dropped {
+		fmt.Println(fmt.Sprintf("[SERVER-LC%d] Forwarding the message of client %d to client %d is dropped", message.Clock, message.ClientId, clientId))
+		s.Trace.Record(trace.Event{Node: s.Node, Type: trace.DROP, Peer: trace.Client(clientId), MessageId: message.MessageId, ClockBefore: message.Clock, Clock: message.Clock, Description: message.Message})
 		return
 	}
 
-	for i, channel := range s.SendChannels{
-		if i != message.ClientId{
+	for copyIndex, delay := range transmission.Delays{
+		if delay > 0 {
+			// the message is in transit while the server carries on
+			go func() {
+				time.Sleep(delay)
+				s.enqueue(clientId, message)
+			}()
+		} else if !s.enqueue(clientId, message) {
+			continue
+		}
 
-			s.Lock.Lock()
-			s.Clock += 1
-			currentClock := s.Clock
-			s.Lock.Unlock()
-			
-			channel <- client.Message{currentClock, message.Message, message.ClientId}
-			fmt.Println(fmt.Sprintf("[SERVER-LC%d] Message forwarded to client %d: '%s'", s.Clock, i, message.Message))
+		if copyIndex > 0 {
+			fmt.Println(fmt.Sprintf("[SERVER-LC%d] Message duplicated to client %d with a latency of %v: '%s'", message.Clock, clientId, delay, message.Message))
+		} else if delay > 0 {
+			fmt.Println(fmt.Sprintf("[SERVER-LC%d] Message forwarded to client %d with a latency of %v: '%s'", message.Clock, clientId, delay, message.Message))
+		} else {
+			fmt.Println(fmt.Sprintf("[SERVER-LC%d] Message forwarded to client %d: '%s'", message.Clock, clientId, message.Message))
+		}
+	}
+}
+
+// function to add a message to a client's queue, returns false if the message is not in the queue.
+// A message dropped because the queue is full is retransmitted once its acknowledgement times out.
+func (s *Server) enqueue(clientId int, message client.Message) bool {
+	s.Lock.Lock()
+	queue := s.Queues[clientId]
+	s.Lock.Unlock()
+	if queue == nil {
+		return false
+	}
+
+	dropped, ok := queue.Push(message)
+	if dropped != nil {
+		s.Lock.Lock()
+		if pending, ok := s.Pending[clientId][dropped.Seq]; ok {
+			pending.Dropped = true
 		}
+		s.Lock.Unlock()
+
+		fmt.Println(fmt.Sprintf("[SERVER-LC%d] Queue to client %d is full, message of client %d dropped (%s): '%s'", dropped.Clock, clientId, dropped.ClientId, queue.Policy, dropped.Message))
+		s.Trace.Record(trace.Event{Node: s.Node, Type: trace.DROP, Peer: trace.Client(clientId), MessageId: dropped.MessageId, ClockBefore: dropped.Clock, Clock: dropped.Clock, Description: dropped.Message})
 	}
+	return ok && (dropped == nil || queue.Policy != DROP_NEWEST)
 }
 
-// coin flip to decide if the server should drop the message
-func (s *Server) coinFlip() bool{
-	rand.Seed(time.Now().UnixNano()) // Making sure this is random using a unique seed
-	return rand.Intn(2) == 1 // Generates random number from 0 to 1
-}
\ No newline at end of file
+// function run by the sender goroutine of a client, putting the messages of its queue on its channel one at a time
+func (s *Server) sendQueued(queue *OutboundQueue, channel chan client.Message, departed chan struct{}) {
+	for{
+		message, ok := queue.Pop()
+		if !ok {
+			return
+		}
+
+		select {
+		case channel <- message:
+		case <-departed:
+			return
+		}
+	}
+}
+
+// function to report the depth of every client's queue
+func (s *Server) QueueMetrics() []QueueMetrics {
+	s.Lock.Lock()
+	ids := s.clientIds()
+	queues := make([]*OutboundQueue, len(ids))
+	for i, id := range ids{
+		queues[i] = s.Queues[id]
+	}
+	s.Lock.Unlock()
+
+	metrics := make([]QueueMetrics, len(ids))
+	for i, queue := range queues{
+		metrics[i] = queue.Metrics(ids[i])
+	}
+	return metrics
+}
+
+// function to list the ids of the clients in the system. Must be called with the lock held
+func (s *Server) clientIds() []int {
+	ids := make([]int, 0, len(s.SendChannels))
+	for id := range s.SendChannels{
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"cmp"
+	"fmt"
+	"lamports-clock/client"
+	"lamports-clock/snapshot"
+	"lamports-clock/trace"
+	"slices"
+)
+
+// Recording of the channel from a client while a snapshot is being taken
+type ChannelRecording struct {
+	MarkerSeq int // every message the client sent before its marker has a sequence number up to this one
+	MarkerReceived bool
+}
+
+// starts a Chandy–Lamport snapshot of the whole system from the server
+func (s *Server) StartSnapshot() {
+	id, ok := s.Snapshots.Start(trace.SERVER)
+	if !ok {
+		fmt.Println("[SERVER] Snapshot not started, another snapshot is still being taken")
+		return
+	}
+
+	end := s.beginStep()
+	s.Lock.Lock()
+	fmt.Println(fmt.Sprintf("[SERVER-LC%d] Snapshot %d initiated", s.Clock.Load(), id))
+	markers := s.recordSnapshot(id)
+	s.Lock.Unlock()
+	end()
+	s.sendMarkers(markers)
+}
+
+// records the local state of the server for a snapshot and starts recording the channels from every client.
+// Returns the markers to send to the clients once the lock is released. Must be called with the lock held
+func (s *Server) recordSnapshot(id int) map[int]client.Message {
+	pending := make([]snapshot.MessageRecord, 0)
+	for i, messages := range s.Pending{
+		for _, p := range messages{
+			pending = append(pending, snapshot.MessageRecord{Seq: p.Message.Seq, Clock: p.Message.Clock, ClientId: p.Message.ClientId, Message: p.Message.Message, MessageId: p.Message.MessageId, To: trace.Client(i), Dropped: p.Dropped})
+		}
+	}
+	slices.SortFunc(pending, func(a, b snapshot.MessageRecord) int {
+		if a.To != b.To {
+			return cmp.Compare(a.To, b.To)
+		}
+		return a.Seq - b.Seq
+	})
+
+	s.SnapshotId = id
+	s.Snapshots.RecordState(id, snapshot.LocalState{Node: trace.SERVER, Clock: s.Clock.Load(), Sent: s.Sent, Received: s.Received, Pending: pending})
+	s.Recording = make(map[int]*ChannelRecording)
+	markers := make(map[int]client.Message)
+	for i := range s.SendChannels{
+		s.Recording[i] = &ChannelRecording{}
+		// the marker tells the client how many messages were forwarded before it, since they may still arrive after it
+		markers[i] = client.Message{Type: client.MARKER, Seq: s.NextSeq[i], Snapshot: id}
+	}
+	fmt.Println(fmt.Sprintf("[SERVER-LC%d] Local state recorded for snapshot %d, sending markers to every client", s.Clock.Load(), id))
+	return markers
+}
+
+// Markers are sent straight to the clients rather than through the simulated network, since the snapshot
+// algorithm needs every marker to arrive, and they are neither timestamped nor traced
+func (s *Server) sendMarkers(markers map[int]client.Message) {
+	for i, marker := range markers{
+		s.Lock.Lock()
+		channel := s.SendChannels[i]
+		s.Lock.Unlock()
+		channel <- marker
+	}
+}
+
+// handles a marker from a client, returning the markers to send if it made the server record its state.
+// Must be called with the lock held
+func (s *Server) handleMarker(clientId int, marker client.Message) map[int]client.Message {
+	var markers map[int]client.Message
+	if marker.Snapshot > s.SnapshotId {
+		markers = s.recordSnapshot(marker.Snapshot)
+	}
+
+	if recording, ok := s.Recording[clientId]; ok && marker.Snapshot == s.SnapshotId {
+		recording.MarkerSeq = marker.Seq
+		recording.MarkerReceived = true
+		s.closeChannel(clientId)
+	}
+	return markers
+}
+
+// adds a message that the client sent before it recorded its state, but that was received after the server
+// recorded its own, to the state of the channel. Must be called with the lock held
+func (s *Server) recordChannelMessage(clientId int, msg client.Message) {
+	if _, ok := s.Recording[clientId]; !ok || msg.Snapshot >= s.SnapshotId {
+		return
+	}
+
+	s.Snapshots.RecordMessage(s.SnapshotId, trace.Client(clientId), trace.SERVER, snapshot.MessageRecord{Seq: msg.Seq, Clock: msg.Clock, ClientId: msg.ClientId, Message: msg.Message, MessageId: msg.MessageId})
+	fmt.Println(fmt.Sprintf("[SERVER-LC%d] Message %d was in transit from client %d for snapshot %d: '%s'", s.Clock.Load(), msg.Seq, clientId, s.SnapshotId, msg.Message))
+	s.closeChannel(clientId)
+}
+
+// stops recording the channel from a client once its marker and every message it sent before the marker
+// have arrived. Must be called with the lock held
+func (s *Server) closeChannel(clientId int) {
+	recording, ok := s.Recording[clientId]
+	if !ok || !recording.MarkerReceived || s.SeqReceived[clientId] < recording.MarkerSeq {
+		return
+	}
+
+	s.Snapshots.CloseChannel(s.SnapshotId, trace.Client(clientId), trace.SERVER)
+	fmt.Println(fmt.Sprintf("[SERVER-LC%d] Channel from client %d recorded for snapshot %d", s.Clock.Load(), clientId, s.SnapshotId))
+	delete(s.Recording, clientId)
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"fmt"
+	"lamports-clock/client"
+	"lamports-clock/trace"
+	"time"
+)
+
+// function to check the clock of a message from a client before the server handles it, returns false if it is rejected.
+// A client whose clock is rejected is quarantined, and its messages are rejected until the quarantine is over.
+// The jump is not checked while the server has lost its clock in a crash, since there is nothing to compare it with
+func (s *Server) validate(clientId int, msg client.Message) bool {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	if s.Validation == nil {
+		return true
+	}
+
+	local := s.Clock.Load()
+	if s.Down || s.Recovering {
+		local = msg.Clock // the clock was lost, so the message can only be checked on its own
+	}
+	reason := s.Validation.Check(msg.Clock, local)
+	if s.isQuarantined(clientId) {
+		reason = "the client is quarantined"
+	}
+	if reason == "" {
+		return true
+	}
+
+	s.Rejected += 1
+	// the message was received even though it is not handled, so a snapshot does not wait for it
+	s.SeqReceived[clientId] = max(s.SeqReceived[clientId], msg.Seq)
+	s.closeChannel(clientId)
+	fmt.Println(fmt.Sprintf("[SERVER-LC%d] Message from client %d rejected, %s: '%s'", s.Clock.Load(), clientId, reason, msg.Message))
+	s.Trace.Record(trace.Event{Node: trace.Client(clientId), Type: trace.DROP, Peer: s.Node, MessageId: msg.MessageId, ClockBefore: msg.Clock, Clock: msg.Clock, Description: "rejected: " + reason})
+	if _, ok := s.Quarantined[clientId]; !ok {
+		s.Quarantined[clientId] = s.Validation.QuarantineUntil()
+		if s.Validation.Quarantine == 0 {
+			fmt.Println(fmt.Sprintf("[SERVER-LC%d] Client %d quarantined for the rest of the run", s.Clock.Load(), clientId))
+		} else {
+			fmt.Println(fmt.Sprintf("[SERVER-LC%d] Client %d quarantined for %v", s.Clock.Load(), clientId, s.Validation.Quarantine))
+		}
+	}
+	return false
+}
+
+// function to check if the messages of a client are still rejected, lifting its quarantine once it is over.
+// Must be called with the lock held
+func (s *Server) isQuarantined(clientId int) bool {
+	until, ok := s.Quarantined[clientId]
+	if !ok {
+		return false
+	}
+	if until.IsZero() || time.Now().Before(until) {
+		return true
+	}
+
+	delete(s.Quarantined, clientId)
+	fmt.Println(fmt.Sprintf("[SERVER-LC%d] Quarantine of client %d is over", s.Clock.Load(), clientId))
+	return false
+}
@@ -0,0 +1,235 @@
+package snapshot
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"lamports-clock/trace"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	Timeout = 10 * time.Second // how long a snapshot waits for every channel to be recorded before it is written incomplete
+)
+
+// Message recorded in the state of a channel, or waiting for an acknowledgement in the server's state
+type MessageRecord struct {
+	Seq int `json:"seq"` // number of the message on its channel
+	Clock int `json:"clock"`
+	ClientId int `json:"client_id"` // client that wrote the message
+	Message string `json:"message"`
+	MessageId string `json:"message_id,omitempty"`
+	To string `json:"to,omitempty"` // recipient of a message waiting for an acknowledgement
+	Dropped bool `json:"dropped,omitempty"` // the network dropped the last transmission of the message
+}
+
+// Local state of a node when it recorded the snapshot
+type LocalState struct {
+	Node string `json:"node"`
+	Clock int `json:"clock"`
+	Sent int `json:"sent"` // messages sent by the node
+	Received int `json:"received"` // messages received by the node
+	SeqReceived int `json:"seq_received,omitempty"` // every message from the server up to this sequence number had been received
+	SeqAhead []int `json:"seq_ahead,omitempty"` // messages from the server received out of order, beyond SeqReceived
+	Pending []MessageRecord `json:"pending,omitempty"` // messages the server forwarded that were not acknowledged yet
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Messages that were in a channel when the snapshot was taken: sent before the sender recorded
+// its state and received after the receiver recorded its state
+type ChannelState struct {
+	From string `json:"from"`
+	To string `json:"to"`
+	Messages []MessageRecord `json:"messages"`
+	Complete bool `json:"complete"` // every message sent before the marker arrived before the snapshot timed out
+}
+
+// Snapshot is a consistent global state of the system assembled from the recordings of every node
+type Snapshot struct {
+	Id int `json:"id"`
+	Initiator string `json:"initiator"`
+	StartedAt time.Time `json:"started_at"`
+	States []LocalState `json:"states"`
+	Channels []*ChannelState `json:"channels"`
+	LostInTransit []MessageRecord `json:"lost_in_transit"` // messages in a channel that the network dropped and were not retransmitted in time
+}
+
+// Collector assembles the snapshots from the recordings of the nodes and writes each one to a file
+// once every node and channel has been recorded. A nil Collector records nothing, so snapshots can be left disabled.
+type Collector struct {
+	Clients int // number of clients, each of them has a channel to and from the server
+	current *Snapshot
+	nextId int
+	lock sync.Mutex
+}
+
+// Starts a new snapshot, returning its id. Only one snapshot can be taken at a time.
+func (c *Collector) Start(initiator string) (int, bool) {
+	if c == nil {
+		return 0, false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.current != nil {
+		return 0, false
+	}
+
+	c.nextId += 1
+	snapshot := &Snapshot{Id: c.nextId, Initiator: initiator, StartedAt: time.Now()}
+	c.current = snapshot
+	time.AfterFunc(Timeout, func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		if c.current == snapshot {
+			fmt.Printf("[SNAPSHOT-%d] Timed out waiting for every channel to be recorded\n", snapshot.Id)
+			c.finish()
+		}
+	})
+	return snapshot.Id, true
+}
+
+// Adds the local state of a node to the snapshot
+func (c *Collector) RecordState(id int, state LocalState) {
+	c.update(id, func(snapshot *Snapshot) {
+		state.RecordedAt = time.Now()
+		snapshot.States = append(snapshot.States, state)
+	})
+}
+
+// Adds a message that was in the channel between two nodes to the snapshot
+func (c *Collector) RecordMessage(id int, from string, to string, message MessageRecord) {
+	c.update(id, func(snapshot *Snapshot) {
+		channel := snapshot.channel(from, to)
+		channel.Messages = append(channel.Messages, message)
+	})
+}
+
+// Marks the recording of the channel between two nodes as complete
+func (c *Collector) CloseChannel(id int, from string, to string) {
+	c.update(id, func(snapshot *Snapshot) {
+		snapshot.channel(from, to).Complete = true
+	})
+}
+
+func (c *Collector) update(id int, change func(*Snapshot)) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.current == nil || c.current.Id != id {
+		return // the snapshot has already been written
+	}
+
+	change(c.current)
+	if c.isComplete() {
+		c.finish()
+	}
+}
+
+// Checking if every node and every channel has been recorded. Must be called with the lock held
+func (c *Collector) isComplete() bool {
+	if len(c.current.States) != c.Clients + 1 {
+		return false
+	}
+
+	closed := 0
+	for _, channel := range c.current.Channels {
+		if channel.Complete {
+			closed += 1
+		}
+	}
+	return closed == 2 * c.Clients
+}
+
+// Writes the current snapshot to a file. Must be called with the lock held
+func (c *Collector) finish() {
+	snapshot := c.current
+	c.current = nil
+	for i := range c.Clients {
+		// channels that were never recorded are still part of the snapshot, as incomplete channels
+		snapshot.channel(trace.SERVER, trace.Client(i))
+		snapshot.channel(trace.Client(i), trace.SERVER)
+	}
+	snapshot.LostInTransit = lostInTransit(snapshot)
+
+	slices.SortFunc(snapshot.States, func(a, b LocalState) int { return compareNodes(a.Node, b.Node) })
+	slices.SortFunc(snapshot.Channels, func(a, b *ChannelState) int {
+		if a.From != b.From {
+			return compareNodes(a.From, b.From)
+		}
+		return compareNodes(a.To, b.To)
+	})
+
+	inTransit := 0
+	for _, channel := range snapshot.Channels {
+		inTransit += len(channel.Messages)
+	}
+
+	path := fmt.Sprintf("snapshot-%d.json", snapshot.Id)
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		fmt.Printf("[SNAPSHOT-%d] Error occurred while writing %s: %s\n", snapshot.Id, path, err)
+		return
+	}
+	fmt.Printf("[SNAPSHOT-%d] Written to %s: %d local states, %d messages in transit, %d messages lost in transit\n", snapshot.Id, path, len(snapshot.States), inTransit, len(snapshot.LostInTransit))
+}
+
+// Every message the server had forwarded to a client but was not acknowledged when the server recorded its state
+// was either received before the client recorded its state, recorded in the channel or dropped by the network.
+// The last ones were lost in transit: they are only delivered once they are retransmitted.
+func lostInTransit(snapshot *Snapshot) []MessageRecord {
+	lost := make([]MessageRecord, 0)
+	server := slices.IndexFunc(snapshot.States, func(state LocalState) bool { return state.Node == trace.SERVER })
+	if server == -1 {
+		return lost
+	}
+
+	for _, pending := range snapshot.States[server].Pending {
+		for _, state := range snapshot.States {
+			if state.Node != pending.To {
+				continue
+			}
+			channel := snapshot.channel(trace.SERVER, pending.To)
+			received := pending.Seq <= state.SeqReceived || slices.Contains(state.SeqAhead, pending.Seq) ||
+				slices.ContainsFunc(channel.Messages, func(m MessageRecord) bool { return m.Seq == pending.Seq })
+			if !received {
+				lost = append(lost, pending)
+			}
+		}
+	}
+	return lost
+}
+
+// Finding the state of the channel between two nodes, adding it if it has not been recorded yet
+func (s *Snapshot) channel(from string, to string) *ChannelState {
+	for _, channel := range s.Channels {
+		if channel.From == from && channel.To == to {
+			return channel
+		}
+	}
+	channel := &ChannelState{From: from, To: to, Messages: make([]MessageRecord, 0)}
+	s.Channels = append(s.Channels, channel)
+	return channel
+}
+
+// Ordering the nodes with the server first and the clients by id
+func compareNodes(a string, b string) int {
+	return cmp.Compare(nodeIndex(a), nodeIndex(b))
+}
+
+func nodeIndex(node string) int {
+	var id int
+	if _, err := fmt.Sscanf(node, "client-%d", &id); err != nil {
+		return -1
+	}
+	return id
+}
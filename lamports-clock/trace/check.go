@@ -0,0 +1,59 @@
+package trace
+
+import (
+	"fmt"
+)
+
+// Invariants checked by Check
+const (
+	CLOCK_CONDITION = "clock condition" // a message is sent with a smaller clock than it is received with
+	MONOTONICITY    = "monotonicity" // every step of a node increments its clock, starting from the clock the previous step left
+	RECORDED_ORDER  = "recorded order" // a message is recorded as sent before it is recorded as received
+)
+
+// Violation is an event that breaks one of the invariants of a logical clock
+type Violation struct {
+	Invariant string
+	Event Event
+	Detail string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s violated by %s %s: %s", v.Invariant, v.Event.Node, Describe(v.Event), v.Detail)
+}
+
+// Checks the Lamport clocks of the events in the order they were recorded.
+// A clock that was read or updated outside the lock shows up as a step that does not continue
+// from the previous step of the node, or as a message received with a clock it was not sent with.
+func Check(events []Event) []Violation {
+	violations := make([]Violation, 0)
+	sends := make(map[string]Event)
+	last := make(map[string]Event) // previous step of each node
+
+	for _, event := range events {
+		if event.Type == DROP {
+			continue
+		}
+
+		if previous, ok := last[event.Node]; ok && event.ClockBefore != previous.Clock {
+			violations = append(violations, Violation{MONOTONICITY, event, fmt.Sprintf("the clock was %d before the step but the previous step left it at %d", event.ClockBefore, previous.Clock)})
+		}
+		if event.Clock <= event.ClockBefore {
+			violations = append(violations, Violation{MONOTONICITY, event, fmt.Sprintf("the clock went from %d to %d", event.ClockBefore, event.Clock)})
+		}
+		last[event.Node] = event
+
+		switch event.Type {
+		case SEND:
+			sends[event.MessageId] = event
+		case RECEIVE:
+			send, ok := sends[event.MessageId]
+			if !ok {
+				violations = append(violations, Violation{RECORDED_ORDER, event, "the message was not recorded as sent before it was received"})
+			} else if send.Clock >= event.Clock {
+				violations = append(violations, Violation{CLOCK_CONDITION, event, fmt.Sprintf("the message was sent with clock %d and received with clock %d", send.Clock, event.Clock)})
+			}
+		}
+	}
+	return violations
+}
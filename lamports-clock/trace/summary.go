@@ -0,0 +1,58 @@
+package trace
+
+import "fmt"
+
+// Summary of how the clocks grew over a run, to compare topologies
+type Summary struct {
+	Nodes []NodeSummary
+	Messages int // messages sent
+	Dropped int // messages lost by the network
+	Delivered int // messages received
+}
+
+type NodeSummary struct {
+	Node string
+	Sent int
+	Received int
+	Clock int // clock after the last event of the node
+}
+
+// Summarizes the events of a run by node
+func Summarize(events []Event) Summary {
+	summary := Summary{}
+	nodes := make(map[string]*NodeSummary)
+	for _, node := range Nodes(events) {
+		summary.Nodes = append(summary.Nodes, NodeSummary{Node: node})
+	}
+	for i := range summary.Nodes {
+		nodes[summary.Nodes[i].Node] = &summary.Nodes[i]
+	}
+
+	for _, event := range events {
+		node := nodes[event.Node]
+		switch event.Type {
+		case SEND:
+			node.Sent += 1
+			summary.Messages += 1
+		case RECEIVE:
+			node.Received += 1
+			summary.Delivered += 1
+		case DROP:
+			summary.Dropped += 1
+			continue
+		}
+		node.Clock = event.Clock
+	}
+	return summary
+}
+
+// Lines describing the summary, one per node and one for the whole run
+func (s Summary) Lines() []string {
+	lines := make([]string, 0, len(s.Nodes) + 1)
+	largest := 0
+	for _, node := range s.Nodes {
+		lines = append(lines, fmt.Sprintf("%s: %d sent, %d received, final clock %d", node.Node, node.Sent, node.Received, node.Clock))
+		largest = max(largest, node.Clock)
+	}
+	return append(lines, fmt.Sprintf("%d messages sent, %d dropped, %d delivered, largest clock %d", s.Messages, s.Dropped, s.Delivered, largest))
+}
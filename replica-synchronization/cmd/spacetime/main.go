@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"replica-synchronization/trace"
+	"slices"
+)
+
+// Merges the trace files written by nodes started with -trace and exports them as a space-time diagram or a ShiViz log
+func main() {
+	dotFile := flag.String("dot", "", "file to write a Graphviz space-time diagram to")
+	svgFile := flag.String("svg", "", "file to write an SVG space-time diagram to")
+	shivizFile := flag.String("shiviz", "", "file to write a ShiViz log to")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: spacetime [-dot file] [-svg file] [-shiviz file] trace-node-0.jsonl trace-node-1.jsonl ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	events := make([]trace.Event, 0)
+	for _, path := range flag.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Printf("Error occurred while opening %s: %s\n", path, err)
+			os.Exit(1)
+		}
+		nodeEvents, err := trace.ReadEvents(file)
+		file.Close()
+		if err != nil {
+			fmt.Printf("Error occurred while reading %s: %s\n", path, err)
+			os.Exit(1)
+		}
+		events = append(events, nodeEvents...)
+	}
+
+	// Events of different nodes are interleaved by wall clock time, the exports then order them causally
+	slices.SortStableFunc(events, func(a, b trace.Event) int {
+		return a.Time.Compare(b.Time)
+	})
+	fmt.Printf("[TRACE] %d events read from %d files\n", len(events), flag.NArg())
+
+	export(events, *dotFile, trace.WriteDOT)
+	export(events, *svgFile, trace.WriteSVG)
+	export(events, *shivizFile, trace.WriteShiViz)
+}
+
+// writes the events to a file in one of the export formats, if a file was given
+func export(events []trace.Event, path string, write func(io.Writer, []trace.Event) error) {
+	if path == "" {
+		return
+	}
+
+	if err := trace.WriteFile(path, write, events); err != nil {
+		fmt.Printf("Error occurred while writing %s: %s\n", path, err)
+		return
+	}
+	fmt.Printf("[TRACE] %d events written to %s\n", len(events), path)
+}
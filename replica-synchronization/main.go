@@ -2,23 +2,41 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/signal"
 	"replica-synchronization/node"
+	"replica-synchronization/trace"
 	"slices"
 	"strconv"
 	"sync"
 	"syscall"
+	"time"
 )
 
 func main() {
+	clockSync := flag.String("sync", node.CRISTIAN, "algorithm the coordinator synchronizes the physical clocks with: cristian, berkeley or none")
+	syncInterval := flag.Duration("sync-interval", 10 * time.Second, "time between two clock synchronizations")
+	maxOffset := flag.Duration("max-offset", 2 * time.Second, "largest offset of the node's physical clock from the real time when it starts")
+	maxDrift := flag.Float64("max-drift", 0.01, "largest rate error of the node's physical clock, 0.01 lets it run up to 1% fast or slow")
+	tracing := flag.Bool("trace", false, "write the node's events to trace-node-<id>.jsonl, to be merged into a space-time diagram with cmd/spacetime")
+	flag.Parse()
+
+	if !slices.Contains(node.ClockSyncs, *clockSync) {
+		fmt.Printf("The clock synchronization must be %s, %s or %s\n", node.CRISTIAN, node.BERKELEY, node.NONE)
+		os.Exit(1)
+	}
+
 	// Create a new node instance
 	n := node.Node{
 		Lock: sync.Mutex{}, 
 		ClientList: make(map[int]string), 
 		Ring: make([]int, 0),
+		Physical: node.RandomPhysicalClock(*maxOffset, *maxDrift),
+		ClockSync: *clockSync,
+		SyncInterval: *syncInterval,
 	}
 
 	nodesList := node.ReadNodesList()
@@ -36,7 +54,6 @@ func main() {
 		n.ClientList[n.Id] = node.LOCALHOST + "8000"
 		nodesList["nodes"] = n.ClientList
 		nodesList["coordinator"] = strconv.Itoa(n.Id)
-		go node.StartCoordinator(&n)
 	} else {
 		n.Id = node.GetUniqueId(nodes) // asserting the type to map[int]string
 		n.ClientList = nodes
@@ -66,7 +83,22 @@ func main() {
 		fmt.Println("Error occurred while marshalling the Ring back into nodes-list.json: ", err)
 	}
 
-	if n.CoordinatorId != n.Id {
+	if *tracing {
+		path := fmt.Sprintf("trace-node-%d.jsonl", n.Id)
+		traceFile, err := os.Create(path)
+		if err != nil {
+			fmt.Println("Error occurred while creating the trace file: ", err)
+			os.Exit(1)
+		}
+		n.Trace = &trace.Recorder{Output: traceFile}
+		fmt.Printf("[TRACE] Events of node %d are written to %s\n", n.Id, path)
+	}
+
+	fmt.Printf("[NODE-%d] Physical clock %v\n", n.Id, n.Physical)
+
+	if n.CoordinatorId == n.Id {
+		go node.StartCoordinator(&n)
+	} else {
 		go node.StartNode(&n)
 	}
 
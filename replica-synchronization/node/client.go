@@ -27,6 +27,7 @@ type ClientNode struct {
 // Invokes the discovery phase of the ring election process
 func (cn *ClientNode) InvokeElection() {
 	fmt.Printf("[NODE-%d] Election initiated\n", cn.Node.Id)
+	cn.Node.internal("election initiated")
 
 	discoverMsg := Message{
 		Type:       DISCOVER,    
@@ -48,6 +49,7 @@ func (cn *ClientNode) InvokeElection() {
 
 // Invoke synchronization of the replica with the coordinator.
 func (cn *ClientNode) InvokeSynchronization(msg *Message, reply *Message) error {
+	cn.Node.receive(*msg, "InvokeSynchronization")
 
 	cn.Node.Lock.Lock()
 	cn.Node.LocalReplica = msg.Payload
@@ -55,10 +57,10 @@ func (cn *ClientNode) InvokeSynchronization(msg *Message, reply *Message) error
 	cn.Node.Lock.Unlock()
 
 	fmt.Printf("[NODE-%d] Replica synchronized with the coordinator. Replica: '%v'. Ring structure %v\n", cn.Node.Id, msg.Payload, cn.Node.Ring)
-	*reply = Message{
+	*reply = cn.Node.reply(*msg, Message{
 		Type:   ACK,
 		NodeId: cn.Node.Id,
-	}
+	})
 
 	// Modify the replica randomly after synchronization to simulate real world scenarios
 	go cn.modifyReplica()
@@ -69,6 +71,8 @@ func (cn *ClientNode) InvokeSynchronization(msg *Message, reply *Message) error
 // DISCOVERY PHASE
 // Function to discover the ring structure and the new coordinator
 func (cn *ClientNode) DiscoverRing(msg Message, reply *Message) error {
+	cn.Node.receive(msg, "DiscoverRing")
+	request := msg
 	curId := cn.Node.Id
 
 	// Only to update the ring structure to include the new node.
@@ -119,16 +123,17 @@ func (cn *ClientNode) DiscoverRing(msg Message, reply *Message) error {
 		}
 	}
 	
-	*reply = Message{
+	*reply = cn.Node.reply(request, Message{
 		Type:   ACK,
 		NodeId: cn.Node.Id,
-	}
+	})
 	return nil
 }
 
 // ANNOUNCEMENT PHASE
 // Function to update the ring with the new ring structure and the coordinator
 func (cn *ClientNode) UpdateRing(msg Message, reply *Message) error {
+	cn.Node.receive(msg, "UpdateRing")
 	curId := cn.Node.Id
 	
 	cn.Node.Lock.Lock()
@@ -164,10 +169,10 @@ func (cn *ClientNode) UpdateRing(msg Message, reply *Message) error {
 
 	fmt.Printf("[NODE-%d] Ring structure updated. New ring: %v\n", cn.Node.Id, cn.Node.Ring)
 
-	*reply = Message{
+	*reply = cn.Node.reply(msg, Message{
 		Type:   ACK,
 		NodeId: cn.Node.Id,
-	}
+	})
 
 	return nil
 }
@@ -181,7 +186,7 @@ func (cn *ClientNode) propagateToSuccessor(successorId int, msg Message, rpcCall
 	defer client.Close()
 
 	var reply Message
-	err = client.Call(rpcCall, msg, &reply)
+	err = cn.Node.call(client, successorId, rpcCall, msg, &reply)
 	if err != nil {
 		// This is done because if the node/coordinator fails, it is not reachable through rpc so this condition is not an error
 		// Error occurs when accessing rpc methods of the node/coordinator
@@ -194,6 +199,7 @@ func (cn *ClientNode) propagateToSuccessor(successorId int, msg Message, rpcCall
 
 // Function to transition the elected ClientNode to a CoordinatorNode
 func (cn *ClientNode) BecomeCoordinator(msg Message, reply *Message) error {
+	cn.Node.receive(msg, "BecomeCoordinator")
 	cn.Node.Lock.Lock()
 	defer cn.Node.Lock.Unlock()
 
@@ -248,11 +254,13 @@ func (cn *ClientNode) BecomeCoordinator(msg Message, reply *Message) error {
 	
 	// Begin Synchronization
 	go coordinator.SynchronizeReplica()
+	go coordinator.SynchronizeClocks()
 
-	*reply = Message{
+	cn.Node.internal("became coordinator")
+	*reply = cn.Node.reply(msg, Message{
 		Type:   ACK,
 		NodeId: cn.Node.Id,
-	}
+	})
 	
 	fmt.Printf("[COORDINATOR-%d] Successfully transitioned to coordinator role\n", cn.Node.Id)
 	return nil
@@ -277,7 +285,7 @@ func (cn *ClientNode) handleNewNodeRingUpdate(msg Message) error {
     defer coordinator.Close()
 
     var reply Message
-    err = coordinator.Call("CoordinatorNode.InitiateRingUpdate", msg, &reply)
+    err = cn.Node.call(coordinator, msg.CoordinatorId, "CoordinatorNode.InitiateRingUpdate", msg, &reply)
     if err != nil {
         return fmt.Errorf("error initiating ring update: %v", err)
     }
@@ -298,14 +306,14 @@ func (cn *ClientNode) handleElectionRingUpdate(msg Message) error {
 
 	// time.Sleep(5 * time.Second) // Uncomment for part 2.3 (a) and part 2.3 (b) to kill a node(coordinator or client) before the new coordinator ID is circulated through the ring.
     var reply Message
-    if err := client.Call("ClientNode.UpdateRing", msg, &reply); err != nil {
+    if err := cn.Node.call(client, msg.CoordinatorId, "ClientNode.UpdateRing", msg, &reply); err != nil {
         return fmt.Errorf("error updating ring: %v", err)
     }
 
 	fmt.Printf("[NODE-%d] Ring update propagated to the new coordinator. New coordinator is node %d\n", cn.Node.Id, msg.CoordinatorId)
 
 	// time.Sleep(5 * time.Second) // Uncomment for part 2.3 (a) right before the newly elected node becomes the coordinator.
-    if err := client.Call("ClientNode.BecomeCoordinator", msg, &reply); err != nil {
+    if err := cn.Node.call(client, msg.CoordinatorId, "ClientNode.BecomeCoordinator", msg, &reply); err != nil {
         return fmt.Errorf("error converting to coordinator: %v", err)
     }
 
@@ -344,7 +352,8 @@ func (cn *ClientNode) modifyReplica() {
 
 	cn.Node.Lock.Lock()
 	cn.Node.LocalReplica[randIndex] = randNum
-	fmt.Printf("[NODE-%d] Replica modified. New replica: '%v'\n", cn.Node.Id, cn.Node.LocalReplica)
+	fmt.Printf("[NODE-%d] Replica modified at %s. New replica: '%v'\n", cn.Node.Id, cn.Node.Physical.Now().Format("15:04:05.000"), cn.Node.LocalReplica)
+	cn.Node.internal(fmt.Sprintf("replica modified: %v", cn.Node.LocalReplica))
 	cn.Node.Lock.Unlock()
 }
 
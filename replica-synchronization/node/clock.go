@@ -0,0 +1,68 @@
+package node
+
+import (
+	"fmt"
+	"net/rpc"
+	"replica-synchronization/trace"
+)
+
+// Function to stamp a message sent to another node with the Lamport clock and record the send
+func (n *Node) stamp(msg Message, peerId int, description string) Message {
+	n.ClockLock.Lock()
+	defer n.ClockLock.Unlock()
+
+	n.Clock += 1
+	msg.Clock = n.Clock
+	msg.SenderId = n.Id
+	msg.MessageId = n.Trace.NewMessageId(trace.Node(n.Id))
+	n.Trace.Record(trace.Event{Node: trace.Node(n.Id), Type: trace.SEND, Peer: trace.Node(peerId), MessageId: msg.MessageId, Clock: n.Clock, Description: description})
+	return msg
+}
+
+// Function to update the Lamport clock when a message from another node arrives and record the receive.
+// Messages without an id come from the node calling its own handlers directly, so they are not received.
+func (n *Node) receive(msg Message, description string) {
+	if msg.MessageId == "" {
+		return
+	}
+
+	n.ClockLock.Lock()
+	defer n.ClockLock.Unlock()
+
+	n.Clock = max(n.Clock, msg.Clock) + 1
+	n.Trace.Record(trace.Event{Node: trace.Node(n.Id), Type: trace.RECEIVE, Peer: trace.Node(msg.SenderId), MessageId: msg.MessageId, Clock: n.Clock, Description: description})
+}
+
+// Function to record an event that does not involve any other node
+func (n *Node) internal(description string) {
+	n.ClockLock.Lock()
+	defer n.ClockLock.Unlock()
+
+	n.Clock += 1
+	n.Trace.Record(trace.Event{Node: trace.Node(n.Id), Type: trace.INTERNAL, Clock: n.Clock, Description: description})
+}
+
+// Function to stamp the reply of an RPC handler, which is sent back to the node that made the request
+func (n *Node) reply(request Message, reply Message) Message {
+	if request.MessageId == "" {
+		return reply
+	}
+	return n.stamp(reply, request.SenderId, reply.Type)
+}
+
+// Function to call an RPC method of another node. The request and the reply are both messages,
+// and a request that fails is recorded as lost.
+func (n *Node) call(client *rpc.Client, peerId int, method string, msg Message, reply *Message) error {
+	msg = n.stamp(msg, peerId, method)
+
+	err := client.Call(method, msg, reply)
+	if err != nil {
+		n.ClockLock.Lock()
+		n.Trace.Record(trace.Event{Node: trace.Node(n.Id), Type: trace.DROP, Peer: trace.Node(peerId), MessageId: msg.MessageId, Clock: msg.Clock, Description: fmt.Sprintf("%s failed: %s", method, err)})
+		n.ClockLock.Unlock()
+		return err
+	}
+
+	n.receive(*reply, method + " reply")
+	return nil
+}
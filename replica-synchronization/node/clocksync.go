@@ -0,0 +1,216 @@
+package node
+
+import (
+	"fmt"
+	"net/rpc"
+	"slices"
+	"time"
+)
+
+// Clock synchronization algorithms run by the coordinator
+const (
+	CRISTIAN = "cristian" // every node sets its clock to the coordinator's time, corrected by half the round trip
+	BERKELEY = "berkeley" // the coordinator averages the clocks of the ring and tells every node how to adjust
+	NONE     = "none"     // the clocks are never synchronized and drift apart
+)
+
+var ClockSyncs = []string{CRISTIAN, BERKELEY, NONE}
+
+const (
+	MaxRoundTrip = 500 * time.Millisecond // clock readings with a longer round trip are too uncertain to be used by the Berkeley algorithm
+)
+
+// Function to periodically synchronize the physical clocks of the nodes with the configured algorithm
+func (cn *CoordinatorNode) SynchronizeClocks() {
+	if cn.Node.ClockSync == NONE {
+		return
+	}
+
+	for {
+		time.Sleep(cn.Node.SyncInterval)
+
+		ids := cn.Node.peerIds()
+		if len(ids) == 0 {
+			continue
+		}
+
+		fmt.Printf("[COORDINATOR-%d] Clock synchronization with the %s algorithm has begun. Physical clock: %s\n", cn.Node.Id, cn.Node.ClockSync, cn.Node.Physical.Now().Format("15:04:05.000"))
+		if cn.Node.ClockSync == CRISTIAN {
+			cn.runCristian(ids)
+		} else {
+			cn.runBerkeley(ids)
+		}
+	}
+}
+
+// Cristian's algorithm with the coordinator as the time server: every node is asked to fetch the coordinator's time
+func (cn *CoordinatorNode) runCristian(ids []int) {
+	for _, id := range ids {
+		var reply Message
+		msg := Message{
+			Type:   TIME,
+			NodeId: cn.Node.Id,
+		}
+		if err := cn.Node.callNode(id, "ClientNode.RunCristian", msg, &reply); err != nil {
+			fmt.Printf("[COORDINATOR-%d] Error occurred while synchronizing the clock of node %d: %s\n", cn.Node.Id, id, err)
+			continue
+		}
+
+		fmt.Printf("[COORDINATOR-%d] Cristian: node %d adjusted its clock by %v, round trip %v\n", cn.Node.Id, id, reply.Adjustment, reply.RoundTrip)
+	}
+}
+
+// Berkeley algorithm: the coordinator estimates the offset of every node from its own clock, averages
+// the offsets including its own and sends every node the adjustment that brings it to the average
+func (cn *CoordinatorNode) runBerkeley(ids []int) {
+	offsets := map[int]time.Duration{cn.Node.Id: 0}
+
+	for _, id := range ids {
+		var reply Message
+		msg := Message{
+			Type:   TIME,
+			NodeId: cn.Node.Id,
+		}
+		sentAt := cn.Node.Physical.Now()
+		if err := cn.Node.callNode(id, "ClientNode.GetTime", msg, &reply); err != nil {
+			fmt.Printf("[COORDINATOR-%d] Error occurred while reading the clock of node %d: %s\n", cn.Node.Id, id, err)
+			continue
+		}
+		receivedAt := cn.Node.Physical.Now()
+		roundTrip := receivedAt.Sub(sentAt).Round(time.Microsecond)
+
+		if roundTrip > MaxRoundTrip {
+			fmt.Printf("[COORDINATOR-%d] Berkeley: clock of node %d ignored, round trip %v is too long\n", cn.Node.Id, id, roundTrip)
+			continue
+		}
+
+		// the node read its clock about half a round trip before the reply arrived
+		offsets[id] = reply.Time.Add(roundTrip / 2).Sub(receivedAt).Round(time.Microsecond)
+		fmt.Printf("[COORDINATOR-%d] Berkeley: node %d is %v ahead of the coordinator, round trip %v\n", cn.Node.Id, id, offsets[id], roundTrip)
+	}
+
+	var total time.Duration
+	for _, offset := range offsets {
+		total += offset
+	}
+	average := (total / time.Duration(len(offsets))).Round(time.Microsecond)
+	fmt.Printf("[COORDINATOR-%d] Berkeley: average offset of %d clocks is %v\n", cn.Node.Id, len(offsets), average)
+
+	for id, offset := range offsets {
+		adjustment := average - offset
+		if id == cn.Node.Id {
+			cn.Node.Physical.Adjust(adjustment)
+			cn.Node.internal(fmt.Sprintf("clock adjusted by %v", adjustment))
+			fmt.Printf("[COORDINATOR-%d] Berkeley: clock adjusted by %v. Error from the real time: %v\n", cn.Node.Id, adjustment, cn.Node.Physical.Error())
+			continue
+		}
+
+		var reply Message
+		msg := Message{
+			Type:       ADJUST,
+			NodeId:     cn.Node.Id,
+			Adjustment: adjustment,
+		}
+		if err := cn.Node.callNode(id, "ClientNode.AdjustClock", msg, &reply); err != nil {
+			fmt.Printf("[COORDINATOR-%d] Error occurred while adjusting the clock of node %d: %s\n", cn.Node.Id, id, err)
+		}
+	}
+}
+
+// Function to read the coordinator's clock, the time server of Cristian's algorithm
+func (cn *CoordinatorNode) GetTime(msg Message, reply *Message) error {
+	cn.Node.receive(msg, "GetTime")
+	*reply = cn.Node.reply(msg, cn.Node.timeReply())
+	return nil
+}
+
+// Function to read the node's clock for the Berkeley algorithm
+func (cn *ClientNode) GetTime(msg Message, reply *Message) error {
+	cn.Node.receive(msg, "GetTime")
+	*reply = cn.Node.reply(msg, cn.Node.timeReply())
+	return nil
+}
+
+// Function to run Cristian's algorithm against the coordinator that asked for it
+func (cn *ClientNode) RunCristian(msg Message, reply *Message) error {
+	cn.Node.receive(msg, "RunCristian")
+
+	var timeReply Message
+	sentAt := cn.Node.Physical.Now()
+	if err := cn.Node.callNode(msg.NodeId, "CoordinatorNode.GetTime", Message{Type: TIME, NodeId: cn.Node.Id}, &timeReply); err != nil {
+		return fmt.Errorf("[NODE-%d] Error occurred while reading the clock of the coordinator: %s", cn.Node.Id, err)
+	}
+	receivedAt := cn.Node.Physical.Now()
+	roundTrip := receivedAt.Sub(sentAt).Round(time.Microsecond)
+
+	// the coordinator read its clock about half a round trip before the reply arrived
+	adjustment := timeReply.Time.Add(roundTrip / 2).Sub(receivedAt).Round(time.Microsecond)
+	cn.Node.Physical.Adjust(adjustment)
+	cn.Node.internal(fmt.Sprintf("clock adjusted by %v", adjustment))
+	fmt.Printf("[NODE-%d] Cristian: clock adjusted by %v using the coordinator's time, round trip %v. Error from the real time: %v\n", cn.Node.Id, adjustment, roundTrip, cn.Node.Physical.Error())
+
+	*reply = cn.Node.reply(msg, Message{
+		Type:       ACK,
+		NodeId:     cn.Node.Id,
+		Adjustment: adjustment,
+		RoundTrip:  roundTrip,
+	})
+	return nil
+}
+
+// Function to apply the adjustment computed by the coordinator in the Berkeley algorithm
+func (cn *ClientNode) AdjustClock(msg Message, reply *Message) error {
+	cn.Node.receive(msg, "AdjustClock")
+
+	cn.Node.Physical.Adjust(msg.Adjustment)
+	cn.Node.internal(fmt.Sprintf("clock adjusted by %v", msg.Adjustment))
+	fmt.Printf("[NODE-%d] Berkeley: clock adjusted by %v. Error from the real time: %v\n", cn.Node.Id, msg.Adjustment, cn.Node.Physical.Error())
+
+	*reply = cn.Node.reply(msg, Message{
+		Type:   ACK,
+		NodeId: cn.Node.Id,
+	})
+	return nil
+}
+
+// Reply carrying a reading of the node's clock
+func (n *Node) timeReply() Message {
+	return Message{
+		Type:   TIME,
+		NodeId: n.Id,
+		Time:   n.Physical.Now(),
+	}
+}
+
+// Function to call an RPC method of the node with the given id over a new connection
+func (n *Node) callNode(id int, method string, msg Message, reply *Message) error {
+	n.Lock.Lock()
+	address, ok := n.ClientList[id]
+	n.Lock.Unlock()
+	if !ok {
+		return fmt.Errorf("node %d is not in the client list", id)
+	}
+
+	client, err := rpc.Dial("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return n.call(client, id, method, msg, reply)
+}
+
+// ids of every other node in the client list
+func (n *Node) peerIds() []int {
+	n.Lock.Lock()
+	defer n.Lock.Unlock()
+
+	ids := make([]int, 0, len(n.ClientList))
+	for id := range n.ClientList {
+		if id != n.Id {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -15,14 +15,15 @@ type CoordinatorNode struct {
 
 // Function to register a new ClientNode with the CoordinatorNode
 func (cn *CoordinatorNode) RegisterNode(msg *Message, reply *Message) error {
+	cn.Node.receive(*msg, "RegisterNode")
 
 	// Initiate Ring discover and ring updating
 	go cn.InitiateRingDiscovery(msg)
 
-	*reply = Message{
+	*reply = cn.Node.reply(*msg, Message{
 		Type:    ACK,
 		NodeId:  cn.Node.Id,
-	}
+	})
 	return nil
 }
 
@@ -51,7 +52,7 @@ func (cn *CoordinatorNode) SynchronizeReplica() {
 					NodeId:  cn.Node.Id,
 					Payload: cn.Node.LocalReplica,
 				}
-				err = client.Call("ClientNode.InvokeSynchronization", msg, &reply)
+				err = cn.Node.call(client, i, "ClientNode.InvokeSynchronization", msg, &reply)
 
 				if err != nil {
 					fmt.Printf("[COORDINATOR-%d] Error occurred while receiving a response from the client node-%d: %s\n", cn.Node.Id, cn.Node.Id, err)
@@ -125,6 +126,7 @@ func (cn *CoordinatorNode) InitiateRingDiscovery(msg *Message) {
 // FOR NEW NODE ADDITION
 // Function to initiate the ring update propagation within the client nodes.
 func (cn *CoordinatorNode) InitiateRingUpdate(msg Message, reply *Message) error {
+	cn.Node.receive(msg, "InitiateRingUpdate")
 	fmt.Printf("[COORDINATOR-%d] Ring update propagation initiated.\n", cn.Node.Id)
 
 	// Update the ring structure
@@ -153,10 +155,10 @@ func (cn *CoordinatorNode) InitiateRingUpdate(msg Message, reply *Message) error
 		}
 	}()
 
-	*reply = Message{
+	*reply = cn.Node.reply(msg, Message{
 		Type:   ACK,
 		NodeId: cn.Node.Id,
-	}
+	})
 	return nil
 }
 
@@ -170,7 +172,7 @@ func (cn *CoordinatorNode) propagateToSuccessor(successorId int, msg Message, rp
 	defer client.Close()
 
 	var reply Message
-	err = client.Call(rpcCall, msg, &reply)
+	err = cn.Node.call(client, successorId, rpcCall, msg, &reply)
 	if err != nil {
 		return fmt.Errorf("[COORDINATOR-%d] Error propagating ring update to node %d: %s", cn.Node.Id, successorId, err)
 	} 
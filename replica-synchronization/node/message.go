@@ -1,14 +1,24 @@
 package node
 
+import (
+	"time"
+)
+
 // implement acknowledgement message and timeout
 
 type Message struct {
-	Type          string // DISCOVER | SYNC | ACK
+	Type          string // DISCOVER | SYNC | TIME | ADJUST | ACK
 	NodeId        int
 	Payload       []int        // replica
 	ClientList    map[int]string // Ring structure
 	Ring          []int
 	CoordinatorId int
+	Clock         int    // Lamport clock of the sender
+	SenderId      int
+	MessageId     string // identifies the send event of the message in the trace
+	Time          time.Time     // reading of the sender's physical clock
+	Adjustment    time.Duration // change of a physical clock made by the clock synchronization
+	RoundTrip     time.Duration // round trip measured by a node running Cristian's algorithm
 }
 
 func (m Message) IsEmpty() bool {
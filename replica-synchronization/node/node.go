@@ -7,6 +7,7 @@ import (
 	"net"
 	"net/rpc"
 	"os"
+	"replica-synchronization/trace"
 	"strconv"
 	"sync"
 	"time"
@@ -24,6 +25,12 @@ type Node struct {
 	Ring          []int
 	CoordinatorId int
 	Lock          sync.Mutex
+	Clock         int // Lamport clock of the node
+	ClockLock     sync.Mutex
+	Trace         *trace.Recorder // records the node's events, nil if tracing is disabled
+	Physical      *PhysicalClock // simulated wall clock of the node
+	ClockSync     string // algorithm used to synchronize the physical clocks while the node is the coordinator
+	SyncInterval  time.Duration // time between two clock synchronizations
 }
 
 const (
@@ -32,6 +39,8 @@ const (
 	ANNOUNCE = "ANNOUNCE" // Announcement phase during election
 	SYNC     = "SYNC" // Synchronizing replica
 	NDISCOVER = "NDISCOVER" // New node discovery
+	TIME     = "TIME" // Reading of a physical clock
+	ADJUST   = "ADJUST" // Adjustment of a physical clock
 	LOCALHOST = "127.0.0.1:"
 )
 
@@ -86,6 +95,7 @@ func StartCoordinator(node *Node) {
 
 	// Begin Synchronization
 	go cn.SynchronizeReplica()
+	go cn.SynchronizeClocks()
 
 	for {
 		conn, err := listener.Accept()
@@ -116,7 +126,7 @@ func RegisterWithCoordinator(node *Node) {
 	}
 	
 	var reply Message
-	err = client.Call("CoordinatorNode.RegisterNode", request, &reply)
+	err = node.call(client, node.CoordinatorId, "CoordinatorNode.RegisterNode", request, &reply)
 	if err != nil {
 		fmt.Printf("Error registering with coordinator: %s\n", err)
 		return
@@ -0,0 +1,55 @@
+package node
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Simulated wall clock of a node. It starts off with an offset from the real time and then
+// runs too fast or too slow by a constant rate, until the clock synchronization adjusts it.
+type PhysicalClock struct {
+	Offset time.Duration // difference from the real time when the clock was created, including every adjustment
+	Drift  float64 // rate error of the clock, 0.01 makes it run 1% fast and -0.01 1% slow
+	origin time.Time // real time when the clock was created
+	lock   sync.Mutex
+}
+
+func NewPhysicalClock(offset time.Duration, drift float64) *PhysicalClock {
+	return &PhysicalClock{Offset: offset, Drift: drift, origin: time.Now()}
+}
+
+// Creates a physical clock with an offset of up to maxOffset and a drift of up to maxDrift in either direction
+func RandomPhysicalClock(maxOffset time.Duration, maxDrift float64) *PhysicalClock {
+	offset := time.Duration((rand.Float64() * 2 - 1) * float64(maxOffset)).Round(time.Millisecond)
+	drift := (rand.Float64() * 2 - 1) * maxDrift
+	return NewPhysicalClock(offset, drift)
+}
+
+// Reading of the clock
+func (p *PhysicalClock) Now() time.Time {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	elapsed := time.Since(p.origin)
+	return p.origin.Add(p.Offset + time.Duration(float64(elapsed) * (1 + p.Drift)))
+}
+
+// Setting the clock forward or back. The clock is stepped rather than slowed down or sped up.
+func (p *PhysicalClock) Adjust(delta time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.Offset += delta
+}
+
+// Difference between the clock and the real time, which only the simulation can know
+func (p *PhysicalClock) Error() time.Duration {
+	return p.Now().Sub(time.Now()).Round(time.Millisecond)
+}
+
+func (p *PhysicalClock) String() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return fmt.Sprintf("offset %v, drift %+.2f%%", p.Offset, p.Drift * 100)
+}
@@ -0,0 +1,176 @@
+package trace
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Spacing of the SVG space-time diagram in pixels
+const (
+	svgColumnWidth = 60
+	svgRowHeight = 80
+	svgMargin = 110
+)
+
+// Writes the events as a Graphviz space-time diagram with one line per node, time going
+// left to right, and an arrow for every message. Render it with `dot -Tsvg`.
+func WriteDOT(w io.Writer, events []Event) error {
+	ordered := CausalOrder(events)
+	ids := eventIds(ordered)
+
+	var b strings.Builder
+	b.WriteString("digraph spacetime {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=point, width=0.12];\n")
+
+	// The line of every node, connecting its events in order
+	for _, node := range Nodes(ordered) {
+		fmt.Fprintf(&b, "\t%q [shape=plaintext, label=%q];\n", node, node)
+		previous := fmt.Sprintf("%q", node)
+		for i, event := range ordered {
+			if event.Node != node || event.Type == DROP {
+				continue
+			}
+			fmt.Fprintf(&b, "\t%s [xlabel=%q, tooltip=%q];\n", ids[i], event.ClockLabel(), Describe(event))
+			fmt.Fprintf(&b, "\t%s -> %s [arrowhead=none, weight=100, color=gray];\n", previous, ids[i])
+			previous = ids[i]
+		}
+	}
+
+	// An arrow for every message, and a dashed arrow to a cross for every message that was lost
+	for _, message := range messages(ordered) {
+		if message.receive != -1 {
+			fmt.Fprintf(&b, "\t%s -> %s [color=blue];\n", ids[message.send], ids[message.receive])
+		}
+		for _, drop := range message.drops {
+			fmt.Fprintf(&b, "\tlost%d [shape=plaintext, label=\"X\", fontcolor=red, tooltip=%q];\n", drop, Describe(ordered[drop]))
+			fmt.Fprintf(&b, "\t%s -> lost%d [color=red, style=dashed];\n", ids[message.send], drop)
+		}
+	}
+
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Writes the events as an SVG space-time diagram that can be opened directly in a browser
+func WriteSVG(w io.Writer, events []Event) error {
+	ordered := CausalOrder(events)
+	nodes := Nodes(ordered)
+	columns := layout(ordered)
+
+	rows := make(map[string]int)
+	for row, node := range nodes {
+		rows[node] = row
+	}
+	width := svgMargin * 2
+	for _, column := range columns {
+		width = max(width, svgMargin * 2 + column * svgColumnWidth)
+	}
+	height := svgRowHeight * (len(nodes) + 1)
+
+	x := func(i int) int { return svgMargin + columns[i] * svgColumnWidth }
+	y := func(node string) int { return svgRowHeight * (rows[node] + 1) }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"monospace\" font-size=\"11\">\n", width, height)
+	b.WriteString("<defs><marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"6\" markerHeight=\"6\" orient=\"auto\"><path d=\"M0,0 L10,5 L0,10 z\" fill=\"blue\"/></marker></defs>\n")
+	fmt.Fprintf(&b, "<rect width=\"%d\" height=\"%d\" fill=\"white\"/>\n", width, height)
+
+	for _, node := range nodes {
+		fmt.Fprintf(&b, "<text x=\"10\" y=\"%d\" font-weight=\"bold\">%s</text>\n", y(node) + 4, html.EscapeString(node))
+		fmt.Fprintf(&b, "<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"gray\"/>\n", svgMargin - 20, y(node), width - 20, y(node))
+	}
+
+	for _, message := range messages(ordered) {
+		send := ordered[message.send]
+		if message.receive != -1 {
+			fmt.Fprintf(&b, "<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"blue\" marker-end=\"url(#arrow)\"/>\n", x(message.send), y(send.Node), x(message.receive), y(ordered[message.receive].Node))
+		}
+		for _, drop := range message.drops {
+			// A lost message goes half way towards its destination and ends in a cross
+			endX, endY := x(message.send) + svgColumnWidth / 2, (y(send.Node) + y(ordered[drop].Peer)) / 2
+			fmt.Fprintf(&b, "<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"red\" stroke-dasharray=\"4\"><title>%s</title></line>\n", x(message.send), y(send.Node), endX, endY, html.EscapeString(Describe(ordered[drop])))
+			fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" fill=\"red\" text-anchor=\"middle\">X</text>\n", endX, endY + 4)
+		}
+	}
+
+	for i, event := range ordered {
+		if event.Type == DROP {
+			continue
+		}
+		fmt.Fprintf(&b, "<circle cx=\"%d\" cy=\"%d\" r=\"4\" fill=\"black\"><title>%s</title></circle>\n", x(i), y(event.Node), html.EscapeString(Describe(event)))
+		fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" text-anchor=\"middle\">%s</text>\n", x(i), y(event.Node) - 10, html.EscapeString(event.ClockLabel()))
+	}
+
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// send, receive and drop events of a single message, as indexes into the ordered events
+type messageEvents struct {
+	send int
+	receive int
+	drops []int
+}
+
+// Pairing up the events of every message that was sent in the trace
+func messages(ordered []Event) []*messageEvents {
+	byId := make(map[string]*messageEvents)
+	result := make([]*messageEvents, 0)
+	for i, event := range ordered {
+		if event.Type == SEND {
+			byId[event.MessageId] = &messageEvents{send: i, receive: -1}
+			result = append(result, byId[event.MessageId])
+		}
+	}
+	for i, event := range ordered {
+		message, ok := byId[event.MessageId]
+		if !ok {
+			continue
+		}
+		switch event.Type {
+		case RECEIVE:
+			message.receive = i
+		case DROP:
+			message.drops = append(message.drops, i)
+		}
+	}
+	return result
+}
+
+// Placing every event in a column so each node's events move left to right and every message
+// is received in a later column than it was sent. Lost messages are drawn from their send event.
+func layout(ordered []Event) []int {
+	columns := make([]int, len(ordered))
+	nextColumn := make(map[string]int)
+	sendColumns := make(map[string]int)
+
+	for i, event := range ordered {
+		if event.Type == DROP {
+			continue
+		}
+		column := nextColumn[event.Node]
+		if sendColumn, ok := sendColumns[event.MessageId]; ok && event.Type == RECEIVE {
+			column = max(column, sendColumn + 1)
+		}
+		columns[i] = column
+		nextColumn[event.Node] = column + 1
+		if event.Type == SEND {
+			sendColumns[event.MessageId] = column
+		}
+	}
+	return columns
+}
+
+// DOT identifier of every event
+func eventIds(ordered []Event) []string {
+	ids := make([]string, len(ordered))
+	for i := range ordered {
+		ids[i] = fmt.Sprintf("e%d", i)
+	}
+	return ids
+}
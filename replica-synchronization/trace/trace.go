@@ -0,0 +1,124 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	SEND     = "send"
+	RECEIVE  = "receive"
+	DROP     = "drop" // message lost because the node it was sent to could not be reached
+	INTERNAL = "internal"
+)
+
+// Event is a single step in the run of a node, along with the node's Lamport clock after the step
+type Event struct {
+	Node string `json:"node"`
+	Type string `json:"type"` // SEND | RECEIVE | DROP | INTERNAL
+	Peer string `json:"peer,omitempty"` // node on the other end of a message
+	MessageId string `json:"message_id,omitempty"` // links the send of a message to its receive
+	Clock int `json:"clock"`
+	Description string `json:"description,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// Recorder keeps every event of a node in the order they were recorded, and writes each of them
+// as a JSON line to Output if it is set. A nil Recorder records nothing, so tracing can be left disabled.
+type Recorder struct {
+	Output io.Writer
+	events []Event
+	messageIds map[string]int
+	lock sync.Mutex
+}
+
+// name of a node in the trace
+func Node(id int) string {
+	return fmt.Sprintf("node-%d", id)
+}
+
+// Clock value shown in diagrams
+func (e Event) ClockLabel() string {
+	return strconv.Itoa(e.Clock)
+}
+
+func (r *Recorder) Record(event Event) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	event.Time = time.Now()
+	r.events = append(r.events, event)
+
+	if r.Output != nil {
+		if err := json.NewEncoder(r.Output).Encode(event); err != nil {
+			fmt.Printf("Error occurred while writing the trace: %s\n", err)
+		}
+	}
+}
+
+// Generates a unique id for a message sent by the given node
+func (r *Recorder) NewMessageId(node string) string {
+	if r == nil {
+		return ""
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.messageIds == nil {
+		r.messageIds = make(map[string]int)
+	}
+	r.messageIds[node] += 1
+	return fmt.Sprintf("%s/%d", node, r.messageIds[node])
+}
+
+// Every event recorded so far
+func (r *Recorder) Events() []Event {
+	if r == nil {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	events := make([]Event, len(r.events))
+	copy(events, r.events)
+	return events
+}
+
+// Reads the events written by a Recorder as JSON lines
+func ReadEvents(r io.Reader) ([]Event, error) {
+	events := make([]Event, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64 * 1024), 1024 * 1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// Writes the events to a file in one of the export formats
+func WriteFile(path string, write func(io.Writer, []Event) error, events []Event) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return write(file, events)
+}
@@ -0,0 +1,84 @@
+package bloom
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// Answers a Bloom clock gives when it compares two events
+const (
+	DEFINITELY_NOT = "definitely not happened-before" // some cell of the first clock is above the second, so the first event is not in the past of the second
+	PROBABLY = "probably happened-before" // every cell of the first clock is at most the second, which a vector clock would confirm unless it is a false positive
+)
+
+// Bloom clock (Ramabaja, "The Bloom Clock"): a fixed number of counters instead of one entry per node. Every event
+// increments the counters its id hashes to, and a receive takes the maximum of every counter first, like a vector
+// clock does with its entries. The clock of an event in the past of another is never above it in any counter, but
+// the counters of different events collide, so the reverse only holds with some probability.
+// The clocks it returns are copies, so they can be kept while the node goes on
+type Clock struct {
+	Cells []int
+	Hashes int // counters every event increments
+}
+
+func New(size int, hashes int) *Clock {
+	return &Clock{Cells: make([]int, size), Hashes: hashes}
+}
+
+func (c *Clock) Copy() *Clock {
+	return &Clock{Cells: append([]int(nil), c.Cells...), Hashes: c.Hashes}
+}
+
+// Increments the counters of the given event of a node, the counter of the event being its number among the
+// node's events. Double hashing derives the counters from two hashes of the event id, so they are
+// spread evenly whatever the number of hashes
+func (c *Clock) Tick(node string, event int) {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%d", node, event)
+	sum := h.Sum64()
+	h1, h2 := sum & 0xffffffff, sum >> 32 | 1
+	for i := range c.Hashes {
+		c.Cells[(h1 + uint64(i) * h2) % uint64(len(c.Cells))] += 1
+	}
+}
+
+// Takes the maximum of every counter, when a node receives a message carrying the other clock
+func (c *Clock) Merge(other *Clock) {
+	for i := range c.Cells {
+		c.Cells[i] = max(c.Cells[i], other.Cells[i])
+	}
+}
+
+// Sum of the counters, the number of events in the clock's past times the number of hashes
+func (c *Clock) Sum() int {
+	sum := 0
+	for _, cell := range c.Cells {
+		sum += cell
+	}
+	return sum
+}
+
+// Compares the clock of an event to the clock of a later one. Returns PROBABLY with the chance that the answer is
+// a false positive, or DEFINITELY_NOT with a chance of 0
+func (c *Clock) Compare(later *Clock) (string, float64) {
+	for i := range c.Cells {
+		if c.Cells[i] > later.Cells[i] {
+			return DEFINITELY_NOT, 0
+		}
+	}
+	return PROBABLY, c.FalsePositiveRate(later)
+}
+
+// Chance that the clock of an event is at most a later clock in every counter although the event is not in its
+// past. The later clock counts at least Sum(later) - Sum(c) increments the event's clock does not, each of which
+// lands on a given counter with probability 1/size, and every one of the event's Hashes counters has to be covered
+func (c *Clock) FalsePositiveRate(later *Clock) float64 {
+	size := float64(len(c.Cells))
+	extra := float64(max(later.Sum() - c.Sum(), 0))
+	return math.Pow(1 - math.Pow(1 - 1 / size, extra), float64(c.Hashes))
+}
+
+func (c *Clock) String() string {
+	return fmt.Sprint(c.Cells)
+}
@@ -0,0 +1,127 @@
+package bloom
+
+import (
+	"fmt"
+	"vector-clock/trace"
+)
+
+// Comparison of the answers of Bloom clocks with the exact happened-before relation of vector clocks on the same
+// trace, over every ordered pair of messages sent by different nodes
+type Comparison struct {
+	Size int
+	Hashes int
+	Events int
+	Entries int // entries of the largest vector clock, against the Size counters of every Bloom clock
+	Pairs int
+	Before int // pairs where the first message happened before the second
+	Probably int // pairs the Bloom clocks answered PROBABLY for
+	FalsePositives int // PROBABLY answers for pairs where the first message did not happen before the second
+	FalseNegatives int // DEFINITELY_NOT answers for pairs where it did, which a Bloom clock never gives
+	Estimated float64 // sum of the false-positive rates the Bloom clocks compute for the pairs that did not happen before
+}
+
+// Rebuilds the Bloom clock of every event of a trace, following the messages like VectorTimestamps does, and
+// compares both clocks of every pair of messages sent by different nodes
+func Compare(events []trace.Event, size int, hashes int) Comparison {
+	steps := make([]trace.Event, 0, len(events))
+	for _, event := range events {
+		// a drop is not a step of the node, it only shows that the message never arrived
+		if event.Type != trace.DROP {
+			steps = append(steps, event)
+		}
+	}
+	ordered := trace.CausalOrder(steps)
+	vectors := trace.VectorTimestamps(ordered)
+
+	current := make(map[string]*Clock)
+	sent := make(map[string]*Clock) // clock of every message when it was sent
+	sends := make([]int, 0) // indexes of the send events
+	clocks := make([]*Clock, len(ordered))
+	comparison := Comparison{Size: size, Hashes: hashes, Events: len(ordered)}
+	for i, event := range ordered {
+		clock, ok := current[event.Node]
+		if !ok {
+			clock = New(size, hashes)
+			current[event.Node] = clock
+		}
+		if received, ok := sent[event.MessageId]; ok && event.Type == trace.RECEIVE {
+			clock.Merge(received)
+		}
+		clock.Tick(event.Node, vectors[i][event.Node])
+		clocks[i] = clock.Copy()
+		comparison.Entries = max(comparison.Entries, len(vectors[i]))
+
+		if event.Type == trace.SEND {
+			sent[event.MessageId] = clocks[i]
+			sends = append(sends, i)
+		}
+	}
+
+	for _, a := range sends {
+		for _, b := range sends {
+			if ordered[a].Node == ordered[b].Node {
+				continue
+			}
+			comparison.Pairs += 1
+			before := happenedBefore(vectors[a], vectors[b])
+			if before {
+				comparison.Before += 1
+			}
+			if !before {
+				comparison.Estimated += clocks[a].FalsePositiveRate(clocks[b])
+			}
+			answer, _ := clocks[a].Compare(clocks[b])
+			if answer == PROBABLY {
+				comparison.Probably += 1
+				if !before {
+					comparison.FalsePositives += 1
+				}
+			} else if before {
+				comparison.FalseNegatives += 1
+			}
+		}
+	}
+	return comparison
+}
+
+// Checking if the event of the first vector timestamp happened before the event of the second
+func happenedBefore(a map[string]int, b map[string]int) bool {
+	for node, value := range a {
+		if value > b[node] {
+			return false
+		}
+	}
+	for node, value := range b {
+		if value > a[node] {
+			return true
+		}
+	}
+	return false
+}
+
+// Share of the pairs where the first message did not happen before the second that were answered PROBABLY, which is
+// the chance the clocks estimate, not the share of the PROBABLY answers that were wrong
+func (c Comparison) MeasuredRate() float64 {
+	if c.Pairs == c.Before {
+		return 0
+	}
+	return float64(c.FalsePositives) / float64(c.Pairs - c.Before)
+}
+
+// Average false-positive rate the Bloom clocks compute for the pairs that did not happen before, to compare with
+// MeasuredRate
+func (c Comparison) EstimatedRate() float64 {
+	if c.Pairs == c.Before {
+		return 0
+	}
+	return c.Estimated / float64(c.Pairs - c.Before)
+}
+
+// Lines describing the comparison
+func (c Comparison) Lines() []string {
+	return []string{
+		fmt.Sprintf("%d counters with %d hashes against vector clocks of up to %d entries, over %d events", c.Size, c.Hashes, c.Entries, c.Events),
+		fmt.Sprintf("%d ordered pairs of messages from different nodes, %d happened before, %d answered %s", c.Pairs, c.Before, c.Probably, PROBABLY),
+		fmt.Sprintf("%d false positives (%.1f%% of the pairs that did not happen before, %.1f%% estimated by the clocks), %d false negatives", c.FalsePositives, 100 * c.MeasuredRate(), 100 * c.EstimatedRate(), c.FalseNegatives),
+	}
+}
@@ -0,0 +1,20 @@
+package bloom
+
+import (
+	"math"
+	"testing"
+)
+
+// both rates are taken over the pairs that did not happen before, whatever the share of the PROBABLY answers
+func TestComparisonRates(t *testing.T) {
+	c := Comparison{Pairs: 100, Before: 60, Probably: 70, FalsePositives: 10, Estimated: 12}
+	if got := c.MeasuredRate(); math.Abs(got - 0.25) > 1e-9 {
+		t.Errorf("measured rate is %v, want 0.25", got)
+	}
+	if got := c.EstimatedRate(); math.Abs(got - 0.3) > 1e-9 {
+		t.Errorf("estimated rate is %v, want 0.3", got)
+	}
+	if got := (Comparison{Pairs: 5, Before: 5}).MeasuredRate(); got != 0 {
+		t.Errorf("measured rate without pairs that did not happen before is %v, want 0", got)
+	}
+}
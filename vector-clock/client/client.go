@@ -1,55 +1,469 @@
 package client
 
 import (
+	"encoding/binary"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
 	"time"
+	"vector-clock/itc"
+	"vector-clock/memory"
+	"vector-clock/schedule"
+	"vector-clock/trace"
+)
+
+const (
+	RedeliveryTimeout = 3 * time.Second // how long a message may wait in the hold-back queue before its predecessors are requested again
 )
 
 type Client struct{
 	Id int
+	Server string // name of the client's server in the trace
 	SendChannel chan Message
 	ReceiveChannel chan Message
-	Clock []int
+	Clock VectorClock
+	Delivered VectorClock // number of messages delivered from each client, used for causal delivery
+	Stamp *itc.Stamp // interval tree clock of the client, nil unless interval tree clocks are enabled
+	Knowledge MatrixClock // what the client knows about the messages every client has delivered, nil unless matrix clocks are enabled
+	Seen VectorClock // merged clocks at which the delivered messages were sent, to detect messages delivered after one that depends on them
+	Routing string // how the client addresses its messages: BROADCAST | DIRECT | LIST | TOPIC | MIXED
+	Topics []string // topics the client may address its messages to
+	HoldBack []HeldMessage // messages received before their causal predecessors
+	Retired map[int]bool // ids of clients that have left the system
+	Quit chan struct{} // closed to make the client leave the system
+	SendInterval time.Duration // time between two messages of the client
+	Messages int // messages the client sends before it stops, 0 if it keeps sending until the run ends
+	Stop chan struct{} // closed to make the client stop sending without leaving the system, nil if it never does
+	Stopped bool // the client has stopped sending, so a leave is sent by Leave itself
+	SeqReceived int // every sequence number up to this one has been received from the server
+	SeqAhead map[int]bool // sequence numbers received out of order, beyond SeqReceived
+	Trace *trace.Recorder // records the client's events, nil if tracing is disabled
+	Schedule *schedule.Schedule // records the order of the client's steps or replays a recorded one, nil if it does neither
+	Arrived []Message // messages from the server waiting for the client's turn to handle them while a schedule is replayed
+	Encoder *DiffEncoder // compresses the clocks sent to the server, nil if compression is disabled
+	Decoder *DiffDecoder // rebuilds the clocks received from the server, nil if compression is disabled
+	Validation *Validation // limits the clocks received from the server have to respect, nil if they are not validated
+	Faulty bool // the client corrupts the clock of some of its messages
+	Memory *memory.Replica // replica of the causal memory the clients share, nil unless they share one
+	Keys []string // keys of the causal memory the client reads and writes
+	Reads int // reads of the causal memory the client makes before every write
+	Lock sync.Mutex
+	SendLock sync.Mutex // held while a message is encoded and sent so the server receives them in the order they were encoded
+}
+
+// message waiting in the hold-back queue along with the time it arrived
+type HeldMessage struct{
+	Message Message
+	ArrivedAt time.Time
 }
 
 // send message function to server
 func (c *Client) SendMessage() {
-	for{
+	for sent := 1; ; sent++{
+		select {
+		case <-c.Quit:
+			c.sendLeave()
+			return
+		case <-c.Stop:
+			c.stopSending()
+			return
+		default:
+		}
+
+		step := c.Schedule.Await(schedule.Step{Node: trace.Client(c.Id), Kind: schedule.SEND})
+		c.Lock.Lock()
+		clockBefore := c.Clock.Copy()
 		c.Clock[c.Id] += 1
-		message := Message{c.Clock, fmt.Sprintf("Hello from client %d", c.Id), c.Id}
-		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Sending message to server: '%s'", c.Id, c.Clock, message.Message))
-		c.SendChannel <- message
-		time.Sleep(5 * time.Second) // each message is sent every 5 seconds
+		message := Message{
+			Type: MESSAGE,
+			Clock: c.Clock.Copy(),
+			SenderClock: c.Clock.Copy(),
+			Message: fmt.Sprintf("Hello from client %d", c.Id),
+			ClientId: c.Id,
+			MessageId: c.Trace.NewMessageId(trace.Client(c.Id)),
+		}
+		if c.Schedule.Replaying() {
+			message.To, message.Topic = step.To, step.Topic // addressed as in the recorded run
+		} else {
+			c.address(&message)
+		}
+		if c.Memory != nil {
+			c.accessMemory(&message)
+		}
+		if !message.IsDirected() {
+			c.Delivered[c.Id] += 1 // a client delivers its own broadcasts immediately
+		}
+		c.updateKnowledge(nil)
+		c.tickStamp(nil)
+		message.Timestamp = c.Delivered.Copy()
+		message.Matrix = c.Knowledge.Copy()
+		message.Stamp = c.peekStamp()
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Sending message to server for %s: '%s'", c.Id, c.Clock, message.Recipients(), message.Message))
+		c.Trace.Record(trace.Event{Node: trace.Client(c.Id), Type: trace.SEND, Peer: c.Server, MessageId: message.MessageId, ClockBefore: clockBefore, Clock: c.Clock.Copy(), Description: message.Message})
+		c.Schedule.Done(schedule.Step{Node: trace.Client(c.Id), Kind: schedule.SEND, To: message.To, Topic: message.Topic})
+		c.corrupt(&message)
+		c.Lock.Unlock()
+
+		c.send(message, nil)
+		if sent == c.Messages {
+			c.stopSending()
+			return
+		}
+
+		// each message is sent every send interval
+		select {
+		case <-c.Quit:
+		case <-c.Stop:
+		case <-time.After(c.SendInterval):
+		}
 	}
 }
 
 func (c *Client) ReceiveMessage(){
 	for{
-		msg := <- c.ReceiveChannel
+		msg := c.nextMessage()
+
+		c.Lock.Lock()
+		if msg.Seq > 0 {
+			// Acknowledging every copy since the acknowledgement of an earlier copy might not have reached the server in time
+			go c.acknowledge(msg.Seq)
+			if c.isRetransmission(msg.Seq) {
+				fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Duplicate of message %d discarded: '%s'", c.Id, c.Clock, msg.Seq, msg.Message))
+				c.Lock.Unlock()
+				continue
+			}
+		}
+
+		// A compressed clock can only be rebuilt once every message encoded before it on the link has arrived
+		decoded := c.Decoder.Decode(msg)
+		if len(decoded) == 0 {
+			fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Message '%s' held back until the messages compressed before it arrive. Messages waiting: %d", c.Id, c.Clock, msg.Message, c.Decoder.Waiting()))
+		}
+
+		left := false
+		for _, msg := range decoded{
+			if left = c.receive(msg); left {
+				break
+			}
+		}
+		c.Schedule.Done(schedule.Step{Node: trace.Client(c.Id), Kind: schedule.RECEIVE, MessageId: msg.MessageId})
+		c.Lock.Unlock()
+		if left {
+			return
+		}
+	}
+}
+
+// handles a message from the server, returns true if the client has left the system. Must be called with the lock held
+func (c *Client) receive(msg Message) bool {
+	if msg.Type == LEAVE && msg.ClientId == c.Id {
+		return c.handleLeave(msg)
+	}
+
+	// The client does not know which clients are in the system, so only the entries it already has are bounded
+	if reason := c.Validation.Check(msg.Clock, c.Clock, nil); reason != "" {
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Message from the server rejected, %s: '%s'", c.Id, c.Clock, reason, msg.Message))
+		c.Trace.Record(trace.Event{Node: c.Server, Type: trace.DROP, Peer: trace.Client(c.Id), MessageId: msg.MessageId, ClockBefore: msg.Clock.Copy(), Clock: msg.Clock.Copy(), Description: "rejected: " + reason})
+		return false
+	}
+
+	if msg.Type == LEAVE {
+		return c.handleLeave(msg)
+	}
+
+	if msg.Write != nil {
+		c.receiveWrite(msg)
+	} else if msg.IsDirected() {
+		// Only broadcasts are delivered in causal order, a directed message is delivered as soon as it arrives
+		c.deliver(msg)
+		c.deliverHeldMessages()
+	} else if c.isDuplicate(msg) {
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Duplicate message from client %d discarded: '%s'", c.Id, c.Clock, msg.ClientId, msg.Message))
+	} else if c.canDeliver(msg) {
+		c.deliver(msg)
+		c.deliverHeldMessages()
+	} else {
+		// Causal predecessors of this message have not been delivered yet, so it has to wait
+		c.HoldBack = append(c.HoldBack, HeldMessage{msg, time.Now()})
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Message '%s' held back until its causal predecessors are delivered. Message Timestamp: %v, Delivered: %v, Queue depth: %d", c.Id, c.Clock, msg.Message, msg.Timestamp, c.Delivered, len(c.HoldBack)))
+	}
+	return false
+}
+
+// periodically asks the server to resend the causal predecessors of messages stuck in the hold-back queue
+func (c *Client) RequestRedelivery() {
+	for{
+		// while a schedule is replayed, the client asks exactly when it did in the recorded run
+		step := schedule.Step{Node: trace.Client(c.Id), Kind: schedule.REQUEST}
+		if c.Schedule.Replaying() {
+			c.Schedule.Await(step)
+		} else {
+			select {
+			case <-c.Quit:
+				return
+			case <-time.After(1 * time.Second):
+			}
+		}
 
-		// Causality Detections
-		if CausalityDetection(msg.Clock, c.Clock) {
-			fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v]Potential Causality Violation detected for message: '%s'. Message Clock: %v", c.Id, c.Clock, msg.Message, msg.Clock))
+		c.Lock.Lock()
+		if !c.Schedule.Replaying() && (len(c.HoldBack) == 0 || time.Since(c.HoldBack[0].ArrivedAt) < RedeliveryTimeout) {
+			c.Lock.Unlock()
+			continue
 		}
 
-		// updating the logical clock by finding the maximum between the two clock values
-		c.Clock = VectorMAX(c.Clock, msg.Clock)
+		clockBefore := c.Clock.Copy()
 		c.Clock[c.Id] += 1
-		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Message received from server: '%s'", c.Id, c.Clock, msg.Message))
+		c.tickStamp(nil)
+		request := Message{
+			Type: REDELIVER,
+			Clock: c.Clock.Copy(),
+			ClientId: c.Id,
+			Timestamp: c.Delivered.Copy(),
+			MessageId: c.Trace.NewMessageId(trace.Client(c.Id)),
+			Matrix: c.Knowledge.Copy(),
+			Stamp: c.peekStamp(),
+		}
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Requesting redelivery of missing messages. Delivered: %v, Queue depth: %d, Oldest message waiting for %v", c.Id, c.Clock, c.Delivered, len(c.HoldBack), time.Since(c.HoldBack[0].ArrivedAt).Round(time.Millisecond)))
+		c.Trace.Record(trace.Event{Node: trace.Client(c.Id), Type: trace.SEND, Peer: c.Server, MessageId: request.MessageId, ClockBefore: clockBefore, Clock: c.Clock.Copy(), Description: "redelivery request"})
+		c.Schedule.Done(step)
+		c.Lock.Unlock()
+
+		if !c.send(request, c.Quit) {
+			return
+		}
+	}
+}
+
+// sends an acknowledgement for a message forwarded by the server.
+// Acknowledgements belong to the delivery layer, so they do not change the vector clock.
+func (c *Client) acknowledge(seq int) {
+	select {
+	case c.SendChannel <- Message{Type: ACK, ClientId: c.Id, Seq: seq}:
+	case <-c.Quit:
+	}
+}
+
+// Checking if a message with this sequence number has already been received, recording it otherwise
+func (c *Client) isRetransmission(seq int) bool {
+	if c.hasReceived(seq) {
+		return true
+	}
+
+	c.SeqAhead[seq] = true
+	for c.SeqAhead[c.SeqReceived + 1] {
+		delete(c.SeqAhead, c.SeqReceived + 1)
+		c.SeqReceived += 1
+	}
+	return false
+}
+
+// makes the client leave the system, even once it has stopped sending
+func (c *Client) Leave() {
+	c.Lock.Lock()
+	close(c.Quit)
+	stopped := c.Stopped
+	c.Lock.Unlock()
+
+	if stopped {
+		c.sendLeave()
+	}
+}
+
+// function to stop sending once the client has sent its messages or the run is over. A leave asked for before
+// the client stopped is still sent here, and Leave sends the ones asked for after it
+func (c *Client) stopSending() {
+	c.Lock.Lock()
+	c.Stopped = true
+	quit := false
+	select {
+	case <-c.Quit:
+		quit = true
+	default:
+	}
+	c.Lock.Unlock()
+
+	if quit {
+		c.sendLeave()
+	}
+}
+
+// tells the server that the client is leaving the system
+func (c *Client) sendLeave() {
+	c.Lock.Lock()
+	clockBefore := c.Clock.Copy()
+	c.Clock[c.Id] += 1
+	c.tickStamp(nil)
+	message := Message{Type: LEAVE, Clock: c.Clock.Copy(), ClientId: c.Id, MessageId: c.Trace.NewMessageId(trace.Client(c.Id)), Stamp: c.giveUpStamp()}
+	fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Leaving the system", c.Id, c.Clock))
+	c.Trace.Record(trace.Event{Node: trace.Client(c.Id), Type: trace.SEND, Peer: c.Server, MessageId: message.MessageId, ClockBefore: clockBefore, Clock: c.Clock.Copy(), Description: "leave"})
+	c.Lock.Unlock()
+
+	c.send(message, nil)
+}
+
+// sends a message to the server, compressing its clock if compression is enabled. The message is abandoned
+// if abandon is closed first, and a nil abandon channel waits for the server however long it takes.
+func (c *Client) send(message Message, abandon <-chan struct{}) bool {
+	c.SendLock.Lock()
+	defer c.SendLock.Unlock()
+
+	encoded := c.Encoder.Encode(message)
+	select {
+	case c.SendChannel <- encoded.OnWire():
+		return true
+	case <-abandon:
+		// the server never sees the message, so the next one has to be compressed against the one before it
+		c.Encoder.Undo()
+		return false
+	}
+}
+
+// handles a client leaving the system, returns true if the client that left is this client
+func (c *Client) handleLeave(msg Message) bool {
+	if msg.ClientId == c.Id {
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Left the system", c.Id, c.Clock))
+		return true
+	}
+
+	c.Retired[msg.ClientId] = true
+	c.updateKnowledge(nil)
+	clockBefore := c.Clock.Copy()
+	c.Clock = VectorMAX(c.Clock, msg.Clock)
+	c.Clock.Retire(c.Retired)
+	c.Clock[c.Id] += 1
+	c.tickStamp(msg.Stamp)
+	fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Client %d left the system, its entry has been retired from the vector clock", c.Id, c.Clock, msg.ClientId))
+	c.Trace.Record(trace.Event{Node: trace.Client(c.Id), Type: trace.RECEIVE, Peer: c.Server, MessageId: msg.MessageId, ClockBefore: clockBefore, Clock: c.Clock.Copy(), Description: fmt.Sprintf("client %d left", msg.ClientId)})
+	return false
+}
+
+// delivers the message to the client by updating the vector clock
+func (c *Client) deliver(msg Message) {
+	// updating the logical clock by finding the maximum between the two clock values
+	clockBefore := c.Clock.Copy()
+	c.compareStamp(msg)
+	c.Clock = VectorMAX(c.Clock, msg.Clock)
+	c.Clock.Retire(c.Retired)
+	c.Clock[c.Id] += 1
+	if !msg.IsDirected() {
+		c.Delivered[msg.ClientId] += 1
+	}
+	c.updateKnowledge(msg.Matrix)
+	c.tickStamp(msg.Stamp)
+	if msg.Type == TOMBSTONE {
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Message %d of client %d was rejected by the server, skipping it", c.Id, c.Clock, msg.Timestamp[msg.ClientId], msg.ClientId))
+	} else {
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Message received from server for %s: '%s'", c.Id, c.Clock, msg.Recipients(), msg.Message))
+	}
+
+	// A message sent before one that was already delivered here arrives too late
+	if msg.SenderClock != nil && CausalityDetection(msg.SenderClock, c.Seen) {
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Causal order violated: '%s' was delivered after a message that depends on it. Sender Clock: %v", c.Id, c.Clock, msg.Message, msg.SenderClock))
+	}
+	c.Seen = VectorMAX(c.Seen, msg.SenderClock)
+	c.Trace.Record(trace.Event{Node: trace.Client(c.Id), Type: trace.RECEIVE, Peer: c.Server, MessageId: msg.MessageId, ClockBefore: clockBefore, Clock: c.Clock.Copy(), Description: msg.Message})
+}
+
+// delivers messages from the hold-back queue until none of them can be delivered
+func (c *Client) deliverHeldMessages() {
+	for{
+		index := slices.IndexFunc(c.HoldBack, func(held HeldMessage) bool {
+			return c.canDeliver(held.Message)
+		})
+		if index == -1 {
+			return
+		}
+
+		held := c.HoldBack[index]
+		c.HoldBack = slices.Delete(c.HoldBack, index, index + 1)
+		c.deliver(held.Message)
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Message '%s' delivered from the hold-back queue after waiting %v. Queue depth: %d", c.Id, c.Clock, held.Message.Message, time.Since(held.ArrivedAt).Round(time.Millisecond), len(c.HoldBack)))
+	}
+}
+
+// Checking the causal delivery condition: the message has to be the next one from its sender
+// and every message the sender had delivered before sending it must already be delivered here
+func (c *Client) canDeliver(msg Message) bool {
+	for i := range msg.Timestamp{
+		if i == msg.ClientId {
+			if msg.Timestamp[i] != c.Delivered[i] + 1 {
+				return false
+			}
+		} else if msg.Timestamp[i] > c.Delivered[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Checking if the message has already been delivered or is already waiting in the hold-back queue
+func (c *Client) isDuplicate(msg Message) bool {
+	if msg.Timestamp[msg.ClientId] <= c.Delivered[msg.ClientId] {
+		return true
+	}
+	return slices.ContainsFunc(c.HoldBack, func(held HeldMessage) bool {
+		return held.Message.ClientId == msg.ClientId && held.Message.Timestamp[msg.ClientId] == msg.Timestamp[msg.ClientId]
+	})
+}
+
+// updates the matrix clock with what the sender of a message knew, and with the messages the client has delivered itself.
+// Must be called with the lock held
+func (c *Client) updateKnowledge(matrix MatrixClock) {
+	if c.Knowledge == nil {
+		return
 	}
+
+	c.Knowledge.Merge(matrix)
+	c.Knowledge[c.Id] = c.Delivered.Copy()
+	c.Knowledge.Retire(c.Retired) // an older message may still carry the rows of clients that have left
 }
 
 // Utility functions for vector clocks
 
-func VectorMAX(clock1 []int, clock2 []int) []int {
-	for i := range clock1{
+// Vector clock keyed by node id, so it grows whenever a new node appears.
+// Missing entries are treated as 0.
+type VectorClock map[int]int
+
+func (vc VectorClock) Copy() VectorClock {
+	clock := make(VectorClock, len(vc))
+	for id, value := range vc{
+		clock[id] = value
+	}
+	return clock
+}
+
+// removes the entries of nodes that have left so older messages can't merge them back in
+func (vc VectorClock) Retire(retired map[int]bool) {
+	for id := range retired{
+		delete(vc, id)
+	}
+}
+
+func (vc VectorClock) String() string {
+	return strings.TrimPrefix(fmt.Sprint(map[int]int(vc)), "map")
+}
+
+// Size of the clock encoded as the number of entries followed by the id and value of every entry as varints
+func (vc VectorClock) EncodedSize() int {
+	buffer := make([]byte, binary.MaxVarintLen64)
+	size := binary.PutUvarint(buffer, uint64(len(vc)))
+	for id, value := range vc{
+		size += binary.PutVarint(buffer, int64(id))
+		size += binary.PutUvarint(buffer, uint64(value))
+	}
+	return size
+}
+
+func VectorMAX(clock1 VectorClock, clock2 VectorClock) VectorClock {
+	for i := range clock2{
 		clock1[i] = max(clock1[i], clock2[i])
 	}
 	return clock1
 }
 
 // Checking for causality violations
-func CausalityDetection(msgClock []int, localClock []int) bool {
+func CausalityDetection(msgClock VectorClock, localClock VectorClock) bool {
 	for i := range msgClock{
 		if msgClock[i] > localClock[i]{
 			return false
@@ -0,0 +1,96 @@
+package client
+
+// DiffEncoder compresses the vector clocks sent over one link with the Singhal–Kshemkalyani differential technique:
+// a message only carries the entries of the clock that changed since the previous message on the link.
+// The receiver rebuilds the clock from the one it rebuilt before, so it has to apply the messages in the order
+// they were encoded. A nil DiffEncoder leaves the clocks uncompressed.
+type DiffEncoder struct {
+	LastSent VectorClock // clock of the previous message on the link
+	NextSeq int // position given to the next compressed message
+	previous VectorClock // LastSent before the last message was encoded, to undo it
+}
+
+// DiffDecoder rebuilds the vector clocks received over one link, enforcing the FIFO order the differences
+// need: a message that overtook one encoded before it waits until that one arrives. A nil DiffDecoder
+// passes the messages through unchanged.
+type DiffDecoder struct {
+	Last VectorClock // clock of the previous message applied
+	Applied int // position of the previous message applied
+	Ahead map[int]Message // messages that arrived before a message encoded ahead of them, by position
+}
+
+func NewDiffEncoder() *DiffEncoder {
+	return &DiffEncoder{LastSent: make(VectorClock)}
+}
+
+func NewDiffDecoder() *DiffDecoder {
+	return &DiffDecoder{Last: make(VectorClock), Ahead: make(map[int]Message)}
+}
+
+// Sets the difference of the message's clock from the previous message on the link. The full clock is kept in
+// the message for the sender, Message.OnWire leaves it out. Messages without a clock are not compressed.
+func (e *DiffEncoder) Encode(message Message) Message {
+	if e == nil || message.Clock == nil {
+		return message
+	}
+
+	e.previous = e.LastSent.Copy()
+	message.Diff = make(VectorClock)
+	for id, value := range message.Clock{
+		if last, ok := e.LastSent[id]; !ok || last != value {
+			message.Diff[id] = value
+			e.LastSent[id] = value
+		}
+	}
+	e.NextSeq += 1
+	message.DiffSeq = e.NextSeq
+	return message
+}
+
+// Forgets the last message encoded, for a message that was never sent
+func (e *DiffEncoder) Undo() {
+	if e == nil || e.previous == nil {
+		return
+	}
+	e.LastSent = e.previous
+	e.previous = nil
+	e.NextSeq -= 1
+}
+
+// Rebuilds the clock of every message that can be applied now that the message has arrived, in the order
+// they were encoded. Nothing is returned if the message has to wait for one encoded before it or is a duplicate.
+// An entry retired by the sender stays in the rebuilt clock, the receiver retires it from its own clock.
+func (d *DiffDecoder) Decode(message Message) []Message {
+	if d == nil || message.DiffSeq == 0 {
+		return []Message{message}
+	}
+
+	decoded := make([]Message, 0, 1)
+	if _, ok := d.Ahead[message.DiffSeq]; ok || message.DiffSeq <= d.Applied {
+		return decoded
+	}
+	d.Ahead[message.DiffSeq] = message
+
+	for{
+		next, ok := d.Ahead[d.Applied + 1]
+		if !ok {
+			return decoded
+		}
+		delete(d.Ahead, d.Applied + 1)
+		d.Applied += 1
+
+		for id, value := range next.Diff{
+			d.Last[id] = value
+		}
+		next.Clock = d.Last.Copy()
+		decoded = append(decoded, next)
+	}
+}
+
+// Number of messages waiting for a message encoded before them
+func (d *DiffDecoder) Waiting() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.Ahead)
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Matrix clock keyed by client id: each row is what the owner of the matrix knows about the causal broadcast
+// timestamp of that client, i.e. how many messages from each client it has delivered. Missing rows and entries are treated as 0.
+// A message is known to be delivered everywhere once its column is large enough in the row of every client.
+type MatrixClock map[int]VectorClock
+
+func (m MatrixClock) Copy() MatrixClock {
+	if m == nil {
+		return nil
+	}
+
+	matrix := make(MatrixClock, len(m))
+	for id, row := range m{
+		matrix[id] = row.Copy()
+	}
+	return matrix
+}
+
+// updating every row with what the other matrix knows about it
+func (m MatrixClock) Merge(other MatrixClock) {
+	for id, row := range other{
+		if m[id] == nil {
+			m[id] = make(VectorClock)
+		}
+		VectorMAX(m[id], row)
+	}
+}
+
+// removes the rows of clients that have left, nobody waits for them to deliver anything anymore
+func (m MatrixClock) Retire(retired map[int]bool) {
+	for id := range retired{
+		delete(m, id)
+	}
+}
+
+// Number of messages from the sender known to be delivered by every one of the clients
+func (m MatrixClock) Stable(senderId int, clientIds []int) int {
+	if len(clientIds) == 0 {
+		return 0
+	}
+
+	stable := m[clientIds[0]][senderId]
+	for _, id := range clientIds[1:]{
+		stable = min(stable, m[id][senderId])
+	}
+	return stable
+}
+
+func (m MatrixClock) String() string {
+	ids := make([]int, 0, len(m))
+	for id := range m{
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	rows := make([]string, len(ids))
+	for i, id := range ids{
+		rows[i] = fmt.Sprintf("%d:%v", id, m[id])
+	}
+	return "[" + strings.Join(rows, " ") + "]"
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+	"vector-clock/memory"
+	"vector-clock/trace"
+)
+
+// reads random keys of the causal memory and writes a random one, the message carries the write to the other
+// replicas instead of a greeting. Reads are local and do not change the vector clock. Must be called with the lock held
+func (c *Client) accessMemory(message *Message) {
+	c.readMemory()
+	write := c.Memory.Write(c.Keys[rand.Intn(len(c.Keys))], fmt.Sprint(rand.Intn(1000)))
+	message.Write = &write
+	message.Message = fmt.Sprintf("write %s=%s", write.Key, write.Value)
+}
+
+// keeps reading random keys of the causal memory every send interval once the client has stopped writing, until done
+// is closed or the client leaves, so the reads also see the writes that arrive after the client's last message
+func (c *Client) ReadMemory(done <-chan struct{}) {
+	for{
+		select {
+		case <-done:
+			return
+		case <-c.Quit:
+			return
+		case <-time.After(c.SendInterval):
+		}
+
+		c.Lock.Lock()
+		c.readMemory()
+		c.Lock.Unlock()
+	}
+}
+
+// reads random keys of the causal memory. Must be called with the lock held
+func (c *Client) readMemory() {
+	for range c.Reads{
+		key := c.Keys[rand.Intn(len(c.Keys))]
+		read := c.Memory.Read(key)
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Read %s", c.Id, c.Clock, describeRead(key, read)))
+	}
+}
+
+// handles a write of another replica. The message is delivered as soon as it arrives, without waiting in the hold-back
+// queue, since the replica holds the write back itself until every write it depends on is applied.
+// Must be called with the lock held
+func (c *Client) receiveWrite(msg Message) {
+	if c.Memory.Has(*msg.Write) {
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Duplicate of write %s from client %d discarded: '%s'", c.Id, c.Clock, msg.Write.Id, msg.ClientId, msg.Message))
+		return
+	}
+
+	clockBefore := c.Clock.Copy()
+	c.compareStamp(msg)
+	c.Clock = VectorMAX(c.Clock, msg.Clock)
+	c.Clock.Retire(c.Retired)
+	c.Clock[c.Id] += 1
+	c.updateKnowledge(msg.Matrix)
+	c.tickStamp(msg.Stamp)
+	c.Trace.Record(trace.Event{Node: trace.Client(c.Id), Type: trace.RECEIVE, Peer: c.Server, MessageId: msg.MessageId, ClockBefore: clockBefore, Clock: c.Clock.Copy(), Description: msg.Message})
+
+	applied := c.Memory.Receive(*msg.Write)
+	if len(applied) == 0 {
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Write %s from client %d held back until the writes it depends on are applied. Write Timestamp: %v, Applied: %v, Writes waiting: %d", c.Id, c.Clock, msg.Write.Id, msg.ClientId, VectorClock(msg.Write.Timestamp), VectorClock(c.Memory.Applied), len(c.Memory.Pending)))
+	}
+	for _, write := range applied{
+		// every message carries one write, so the messages delivered from a client are the writes applied from it
+		c.Delivered[write.Replica] = c.Memory.Applied[write.Replica]
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Applied write %s from client %d: %s=%s. Applied: %v, Writes waiting: %d", c.Id, c.Clock, write.Id, write.Replica, write.Key, write.Value, VectorClock(c.Memory.Applied), len(c.Memory.Pending)))
+	}
+}
+
+// description of the write a read returned
+func describeRead(key string, write memory.Write) string {
+	if write.Id == "" {
+		return key + ", never written"
+	}
+	return fmt.Sprintf("%s=%s, written by client %d (write %s)", write.Key, write.Value, write.Replica, write.Id)
+}
@@ -1,11 +1,47 @@
 package client
 
+import (
+	"vector-clock/itc"
+	"vector-clock/memory"
+)
+
+const (
+	MESSAGE   = "MESSAGE"   // Message of a client to every other client, or to the clients it is addressed to
+	REDELIVER = "REDELIVER" // Request for the server to resend messages missing at the client
+	LEAVE     = "LEAVE"     // Client leaving the system, or the server announcing that a client has left
+	ACK       = "ACK"       // Acknowledgement of a message forwarded by the server
+	TOMBSTONE = "TOMBSTONE" // Stand-in the server broadcasts for a broadcast it rejected, keeping its place in the sender's sequence
+)
+
 type Message struct{
-	Clock []int
+	Type string // MESSAGE | REDELIVER | LEAVE | ACK | TOMBSTONE
+	Clock VectorClock
+	SenderClock VectorClock // clock of the client that sent the message when it sent it, Clock is the clock of the last hop
 	Message string
+	To []int // clients the message is addressed to, nil if it is broadcast or addressed to a topic
+	Topic string // topic the message is addressed to, empty if it is not
 	ClientId int
+	Timestamp VectorClock // Causal broadcast timestamp: number of messages from each client the sender had delivered when sending
+	Seq int // sequence number of the message on the link from the server to the recipient, 0 if it is not retransmitted, a rumor's number at the peer that started it in the serverless mode
+	MessageId string // identifies the send event of the message in the trace
+	Diff VectorClock // entries of Clock that changed since the sender's previous message on the link, sent instead of Clock when compression is enabled
+	Stamp *itc.Stamp // interval tree clock of the sender, peeked except in a client's LEAVE, only sent when interval tree clocks are enabled
+	Matrix MatrixClock // what the sender knows about the messages every client has delivered, only sent in matrix clock mode
+	Sender int // peer that pushed the message in the serverless mode, ClientId is the peer that started the rumor
+	DiffSeq int // position of the message among the compressed messages on its link, 0 if its clock is not compressed
+	RelayedBy string // server that relayed the message to this one in a federation, empty if it comes from its sender's server
+	Write *memory.Write // write to the causal memory the message carries to the other replicas, nil unless the clients share one
 }
 
 func (m Message) IsEmpty() bool {
 	return m.Clock == nil && m.Message == "" && m.ClientId == 0
-}
\ No newline at end of file
+}
+
+// copy of the message as it is put on the channel: a message with a compressed clock leaves out the full clock,
+// which the sender only keeps for its own logs
+func (m Message) OnWire() Message {
+	if m.DiffSeq > 0 {
+		m.Clock = nil
+	}
+	return m
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+)
+
+// How a client addresses its messages
+const (
+	BROADCAST = "broadcast" // to every other client
+	DIRECT    = "direct"    // to one random client
+	LIST      = "list"      // to a random list of clients
+	TOPIC     = "topic"     // to the clients subscribed to a random topic
+	MIXED     = "mixed"     // any of the above, picked at random for every message
+)
+
+// MIXED comes last so the others can be picked from the rest of the list
+var RoutingModes = []string{BROADCAST, DIRECT, LIST, TOPIC, MIXED}
+
+// Addressing the message according to the client's routing mode. The client picks recipients among the clients
+// it has heard of, i.e. the ones with an entry in its vector clock. Must be called with the lock held
+func (c *Client) address(message *Message) {
+	mode := c.Routing
+	if mode == MIXED {
+		mode = RoutingModes[rand.Intn(len(RoutingModes) - 1)]
+	}
+
+	peers := make([]int, 0, len(c.Clock))
+	for id := range c.Clock{
+		if id >= 0 && id != c.Id && !c.Retired[id] {
+			peers = append(peers, id)
+		}
+	}
+	slices.Sort(peers)
+
+	switch {
+	case mode == DIRECT && len(peers) > 0:
+		message.To = []int{peers[rand.Intn(len(peers))]}
+	case mode == LIST && len(peers) > 0:
+		rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+		message.To = peers[:1 + rand.Intn(len(peers))]
+		slices.Sort(message.To)
+	case mode == TOPIC && len(c.Topics) > 0:
+		message.Topic = c.Topics[rand.Intn(len(c.Topics))]
+	}
+}
+
+// Checking if the message is addressed to some clients only rather than broadcast to all of them
+func (m Message) IsDirected() bool {
+	return m.To != nil || m.Topic != ""
+}
+
+// describes who the message is addressed to
+func (m Message) Recipients() string {
+	switch {
+	case m.Topic != "":
+		return fmt.Sprintf("topic %s", m.Topic)
+	case len(m.To) == 1:
+		return fmt.Sprintf("client %d", m.To[0])
+	case m.To != nil:
+		return fmt.Sprintf("clients %v", m.To)
+	default:
+		return "everyone"
+	}
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"fmt"
+	"vector-clock/itc"
+	"vector-clock/trace"
+)
+
+// registers an event of the client in its interval tree clock, after joining the causal history of the stamp
+// of a message it received. Must be called with the lock held
+func (c *Client) tickStamp(received *itc.Stamp) {
+	if c.Stamp == nil {
+		return
+	}
+
+	stamp := *c.Stamp
+	if received != nil {
+		stamp = itc.Join(stamp, received.Peek())
+	}
+	stamp = stamp.Event()
+	c.Stamp = &stamp
+}
+
+// compares the stamp of a message with the client's stamp before the client receives it, reporting a message
+// concurrent with the last event of the client. Must be called with the lock held
+func (c *Client) compareStamp(msg Message) {
+	if c.Stamp == nil || msg.Stamp == nil {
+		return
+	}
+
+	if itc.Compare(*msg.Stamp, *c.Stamp) == itc.CONCURRENT {
+		fmt.Println(fmt.Sprintf("[CLIENT-%d-ITC%v] Message '%s' is concurrent with the last event of the client. Message Stamp: %v", c.Id, c.Stamp.Events, msg.Message, msg.Stamp.Events))
+	}
+}
+
+// stamp to send in a message, which only carries the causal history of the client. Must be called with the lock held
+func (c *Client) peekStamp() *itc.Stamp {
+	if c.Stamp == nil {
+		return nil
+	}
+	peek := c.Stamp.Peek()
+	return &peek
+}
+
+// hands the whole stamp over to be joined into the server's, keeping an anonymous stamp that can still receive
+// messages until the leave is confirmed but can't register events anymore. Must be called with the lock held
+func (c *Client) giveUpStamp() *itc.Stamp {
+	stamp := c.Stamp
+	c.Stamp = c.peekStamp()
+	return stamp
+}
+
+// Forks the interval tree clock of the client, keeping one half of its id and handing the other half to a new
+// participant, which starts off with the same causal history. The fork is a message from the client to the new
+// participant, so the vector clocks see the same causal history as the stamps. Returns nil if the client has no id to fork
+func (c *Client) Fork() *Message {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	if c.Stamp == nil || c.Stamp.IsAnonymous() {
+		return nil
+	}
+
+	clockBefore := c.Clock.Copy()
+	c.Clock[c.Id] += 1
+	c.tickStamp(nil)
+	kept, forked := c.Stamp.Fork()
+	c.Stamp = &kept
+	fork := Message{Message: "fork", Clock: c.Clock.Copy(), ClientId: c.Id, MessageId: c.Trace.NewMessageId(trace.Client(c.Id)), Stamp: &forked}
+	c.Trace.Record(trace.Event{Node: trace.Client(c.Id), Type: trace.SEND, MessageId: fork.MessageId, ClockBefore: clockBefore, Clock: c.Clock.Copy(), Description: fork.Message})
+	fmt.Println(fmt.Sprintf("[CLIENT-%d-ITC%v] Forked a new participant with stamp %v", c.Id, kept, forked))
+	return &fork
+}
+
+// Starts a new participant off with the causal history of the client it was forked off, before it runs
+func (c *Client) ReceiveFork(fork Message) {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	clockBefore := c.Clock.Copy()
+	c.Clock = VectorMAX(c.Clock, fork.Clock)
+	c.Clock.Retire(c.Retired)
+	c.Clock[c.Id] += 1
+	c.tickStamp(nil) // the stamp was forked with the causal history of the client already
+	fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Forked off client %d", c.Id, c.Clock, fork.ClientId))
+	c.Trace.Record(trace.Event{Node: trace.Client(c.Id), Type: trace.RECEIVE, Peer: trace.Client(fork.ClientId), MessageId: fork.MessageId, ClockBefore: clockBefore, Clock: c.Clock.Copy(), Description: fork.Message})
+}
+
+// Encoded size in bytes of the client's vector clock and of its interval tree clock
+func (c *Client) ClockSizes() (int, int) {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	if c.Stamp == nil {
+		return c.Clock.EncodedSize(), 0
+	}
+	return c.Clock.EncodedSize(), c.Stamp.EncodedSize()
+}
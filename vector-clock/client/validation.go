@@ -0,0 +1,73 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+const (
+	FaultProbability = 0.2 // chance that a faulty client corrupts the clock of a message it sends
+	FaultJump = 1000000 // how far ahead a faulty client pushes an entry of a corrupted clock, and the offset of the phantom ids it adds
+)
+
+// Limits an incoming clock has to respect before it is merged into the local clock, nil if clocks are not validated
+type Validation struct {
+	MaxJump int // how far ahead of the local entry an entry of an incoming clock may be, 0 if the jump is not bounded
+	Quarantine time.Duration // how long the messages of a sender are rejected after one of its clocks was, 0 for the rest of the run
+}
+
+// Checking an incoming clock against the local clock, returns why it is rejected or "" if it is valid.
+// A receiver that knows which nodes are in the system passes isNode to reject the entries of any other node,
+// and compares every entry with its own. One that does not, like a client, can only bound the entries it already has.
+func (v *Validation) Check(clock VectorClock, local VectorClock, isNode func(id int) bool) string {
+	if v == nil {
+		return ""
+	}
+
+	ids := make([]int, 0, len(clock))
+	for id := range clock{
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	for _, id := range ids{
+		if isNode != nil && !isNode(id) {
+			return fmt.Sprintf("clock %v has an entry for %d, which is not a node of the system", clock, id)
+		}
+		if clock[id] < 0 {
+			return fmt.Sprintf("entry %d of clock %v is negative", id, clock)
+		}
+		previous, ok := local[id]
+		if v.MaxJump > 0 && (ok || isNode != nil) && clock[id] - previous > v.MaxJump {
+			return fmt.Sprintf("entry %d of clock %v is %d ahead of the local entry %d, the limit is %d", id, clock, clock[id] - previous, previous, v.MaxJump)
+		}
+	}
+	return ""
+}
+
+// Time until which a sender is quarantined when one of its clocks is rejected now, the zero time if it is for the rest of the run
+func (v *Validation) QuarantineUntil() time.Time {
+	if v == nil || v.Quarantine == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(v.Quarantine)
+}
+
+// corrupts the clock of a message the way a faulty or malicious client would once in a while, either by pushing
+// its own entry far ahead or by adding an entry for a node that does not exist.
+// Only the message is corrupted, the client's own clock carries on as usual
+func (c *Client) corrupt(message *Message) {
+	if !c.Faulty || rand.Float64() >= FaultProbability {
+		return
+	}
+
+	message.Clock = message.Clock.Copy()
+	if rand.Intn(2) == 0 {
+		message.Clock[c.Id] += FaultJump
+	} else {
+		message.Clock[c.Id + FaultJump] = 1
+	}
+	fmt.Println(fmt.Sprintf("[CLIENT-%d-VC%v] Faulty client corrupting the clock of its message to %v", c.Id, c.Clock, message.Clock))
+}
@@ -0,0 +1,104 @@
+package clock
+
+import (
+	"sync"
+	"sync/atomic"
+	"vector-clock/client"
+)
+
+// Backends a vector clock shared by several goroutines can be kept in
+const (
+	MUTEX = "mutex" // a map guarded by a mutex
+	COPY_ON_WRITE = "cow" // an immutable map replaced with compare-and-swap, so readers never wait for a writer
+)
+
+var Backends = []string{MUTEX, COPY_ON_WRITE}
+
+// Vector clock that several goroutines update at once. The clocks it returns are never modified afterwards,
+// so they can be kept, logged and put in messages, and must not be modified by the caller either
+type Vector interface {
+	Load() client.VectorClock
+	Update(change func(clock client.VectorClock)) (client.VectorClock, client.VectorClock) // applies a change to the clock, returns its value before and after. The change may run more than once, so it must only modify the clock it is given
+	Store(clock client.VectorClock) // replaces the clock, like a node that lost it in a crash or recovered it does
+	Contention() int // updates that had to wait for the update of another goroutine, or retry after it
+}
+
+func NewVector(backend string) Vector {
+	if backend == COPY_ON_WRITE {
+		c := &CopyOnWriteVector{}
+		c.Store(make(client.VectorClock))
+		return c
+	}
+	return &MutexVector{Value: make(client.VectorClock)}
+}
+
+// Vector clock guarded by a mutex, copied whenever it is read so the copies can be handed out
+type MutexVector struct {
+	Value client.VectorClock
+	Contended atomic.Int64 // lock acquisitions that found the lock held by another goroutine
+	Lock sync.Mutex
+}
+
+// takes the lock, counting the times it was held by another goroutine
+func (c *MutexVector) lock() {
+	if !c.Lock.TryLock() {
+		c.Contended.Add(1)
+		c.Lock.Lock()
+	}
+}
+
+func (c *MutexVector) Load() client.VectorClock {
+	c.lock()
+	defer c.Lock.Unlock()
+	return c.Value.Copy()
+}
+
+func (c *MutexVector) Update(change func(clock client.VectorClock)) (client.VectorClock, client.VectorClock) {
+	c.lock()
+	defer c.Lock.Unlock()
+	before := c.Value.Copy()
+	change(c.Value)
+	return before, c.Value.Copy()
+}
+
+func (c *MutexVector) Store(clock client.VectorClock) {
+	c.lock()
+	defer c.Lock.Unlock()
+	c.Value = clock.Copy()
+}
+
+func (c *MutexVector) Contention() int {
+	return int(c.Contended.Load())
+}
+
+// Vector clock that is never modified in place. An update copies the current clock, changes the copy and swaps
+// it in, retrying on a fresh copy if another goroutine swapped in its own first. A read is a single atomic load
+type CopyOnWriteVector struct {
+	Value atomic.Pointer[client.VectorClock]
+	Retries atomic.Int64 // compare-and-swaps that failed because another goroutine updated the clock first
+}
+
+func (c *CopyOnWriteVector) Load() client.VectorClock {
+	return *c.Value.Load()
+}
+
+func (c *CopyOnWriteVector) Update(change func(clock client.VectorClock)) (client.VectorClock, client.VectorClock) {
+	for{
+		current := c.Value.Load()
+		next := current.Copy()
+		change(next)
+		if c.Value.CompareAndSwap(current, &next) {
+			return *current, next
+		}
+		c.Retries.Add(1)
+	}
+}
+
+func (c *CopyOnWriteVector) Store(clock client.VectorClock) {
+	stored := clock.Copy()
+	c.Value.Store(&stored)
+}
+
+func (c *CopyOnWriteVector) Contention() int {
+	return int(c.Retries.Load())
+}
@@ -0,0 +1,55 @@
+package clock
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"vector-clock/client"
+)
+
+// numbers of synthetic clients updating a clock at once, each adding an entry to it
+var benchmarkClients = []int{10, 100, 1000, 5000}
+
+func BenchmarkVectorMutex(b *testing.B) {
+	benchmarkVector(b, MUTEX)
+}
+
+func BenchmarkVectorCopyOnWrite(b *testing.B) {
+	benchmarkVector(b, COPY_ON_WRITE)
+}
+
+// updates a clock the way the server does for every message, merging the clock of the message and ticking to forward
+// it, from one goroutine per client. The clock has an entry for every client, so every update copies all of them. Reports the messages handled per second and the updates that had
+// to wait for or retry after the update of another goroutine
+func benchmarkVector(b *testing.B, backend string) {
+	for _, clients := range benchmarkClients{
+		b.Run(fmt.Sprintf("clients=%d", clients), func(b *testing.B) {
+			// the clock starts off with an entry for every client, so the first updates cost as much as the last ones
+			initial := make(client.VectorClock)
+			for id := range clients{
+				initial[id] = 0
+			}
+			shared := NewVector(backend)
+			shared.Store(initial)
+			var nextId atomic.Int64
+			b.SetParallelism(max(1, clients / runtime.GOMAXPROCS(0)))
+			b.RunParallel(func(pb *testing.PB) {
+				id := int(nextId.Add(1)) % clients
+				sent := client.VectorClock{id: 0}
+				for pb.Next(){
+					sent[id] += 1
+					shared.Update(func(clock client.VectorClock) {
+						client.VectorMAX(clock, sent)
+						clock[-1] += 1
+					})
+					shared.Update(func(clock client.VectorClock) {
+						clock[-1] += 1
+					})
+				}
+			})
+			b.ReportMetric(float64(b.N) / b.Elapsed().Seconds(), "msgs/sec")
+			b.ReportMetric(float64(shared.Contention()) / float64(b.N), "contended/msg")
+		})
+	}
+}
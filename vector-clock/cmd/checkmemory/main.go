@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"vector-clock/memory"
+	"os"
+)
+
+// Checks a history of the causal memory written with -history for reads that are not causally consistent
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Println("Usage: checkmemory history.jsonl")
+		os.Exit(2)
+	}
+
+	file, err := os.Open(os.Args[1])
+	if err != nil {
+		fmt.Println("Error occurred while opening the history: ", err)
+		os.Exit(2)
+	}
+	defer file.Close()
+
+	operations, err := memory.ReadOperations(file)
+	if err != nil {
+		fmt.Println("Error occurred while reading the history: ", err)
+		os.Exit(2)
+	}
+
+	violations := memory.Check(operations)
+	for _, violation := range violations {
+		fmt.Println(violation)
+	}
+	fmt.Printf("[CHECK] %d operations checked, %d violations found\n", len(operations), len(violations))
+	if len(violations) > 0 {
+		os.Exit(1)
+	}
+}
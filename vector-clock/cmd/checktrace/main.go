@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"vector-clock/trace"
+	"os"
+)
+
+// Checks an event log written with -log for violations of the vector clock invariants
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Println("Usage: checktrace events.jsonl")
+		os.Exit(2)
+	}
+
+	file, err := os.Open(os.Args[1])
+	if err != nil {
+		fmt.Println("Error occurred while opening the event log: ", err)
+		os.Exit(2)
+	}
+	defer file.Close()
+
+	events, err := trace.ReadEvents(file)
+	if err != nil {
+		fmt.Println("Error occurred while reading the event log: ", err)
+		os.Exit(2)
+	}
+
+	violations := trace.Check(events)
+	for _, violation := range violations {
+		fmt.Println(violation)
+	}
+	fmt.Printf("[CHECK] %d events checked, %d violations found\n", len(events), len(violations))
+	if len(violations) > 0 {
+		os.Exit(1)
+	}
+}
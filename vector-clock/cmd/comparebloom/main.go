@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"vector-clock/bloom"
+	"vector-clock/trace"
+)
+
+// Compares Bloom clocks of several sizes with the vector clocks of an event log written with -log, by rebuilding
+// both from the messages of the same trace
+func main() {
+	sizes := flag.String("sizes", "4,8,16,32,64", "comma-separated numbers of counters of the Bloom clocks to compare")
+	hashes := flag.Int("hashes", 2, "number of counters every event increments")
+	flag.Usage = func() {
+		fmt.Println("Usage: comparebloom [-sizes 4,8,16] [-hashes 2] events.jsonl")
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	counts := make([]int, 0)
+	for _, field := range strings.Split(*sizes, ",") {
+		size, err := strconv.Atoi(field)
+		if err != nil || size < 1 {
+			fmt.Printf("Invalid size %q, the sizes must be positive numbers\n", field)
+			os.Exit(2)
+		}
+		counts = append(counts, size)
+	}
+	if *hashes < 1 {
+		fmt.Println("The Bloom clocks need at least one hash")
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Println("Error occurred while opening the event log: ", err)
+		os.Exit(2)
+	}
+	defer file.Close()
+
+	events, err := trace.ReadEvents(file)
+	if err != nil {
+		fmt.Println("Error occurred while reading the event log: ", err)
+		os.Exit(2)
+	}
+
+	fmt.Printf("%8s %8s %10s %10s %10s %10s %10s %10s\n", "counters", "entries", "pairs", "before", "probably", "false pos", "measured", "estimated")
+	for _, size := range counts {
+		c := bloom.Compare(events, size, *hashes)
+		fmt.Printf("%8d %8d %10d %10d %10d %10d %9.1f%% %9.1f%%\n", c.Size, c.Entries, c.Pairs, c.Before, c.Probably, c.FalsePositives, 100 * c.MeasuredRate(), 100 * c.EstimatedRate())
+		if c.FalseNegatives > 0 {
+			fmt.Printf("%d pairs that happened before were answered %s\n", c.FalseNegatives, bloom.DEFINITELY_NOT)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"vector-clock/client"
+	"vector-clock/server"
+)
+
+// Compares the bytes the vector clocks take on the wire as a dense vector of every entry, as a sparse map of the
+// entries the clock has seen and with the Singhal–Kshemkalyani differential technique, by replaying the same
+// broadcasts through the server with every encoding
+func main() {
+	rounds := flag.Int("rounds", 100, "number of broadcasts simulated for each system size")
+	seed := flag.Int64("seed", 1, "seed picking the client that broadcasts in each round")
+	flag.Parse()
+
+	if *rounds <= 0 {
+		fmt.Println("The number of rounds must be positive")
+		os.Exit(2)
+	}
+
+	// the savings are measured against the dense vector, the clock of a textbook implementation
+	fmt.Printf("%8s %10s %14s %14s %14s %12s %10s\n", "nodes", "messages", "dense bytes", "sparse bytes", "diff bytes", "entries/msg", "saved")
+	for _, nodes := range []int{10, 100, 1000} {
+		result := simulate(nodes, *rounds, rand.New(rand.NewSource(*seed)))
+		fmt.Printf("%8d %10d %14d %14d %14d %12.1f %9.1f%%\n", nodes, result.Messages, result.DenseBytes, result.SparseBytes, result.DiffBytes,
+			float64(result.DiffEntries) / float64(result.Messages), 100 * (1 - float64(result.DiffBytes) / float64(result.DenseBytes)))
+	}
+}
+
+type result struct {
+	Messages int
+	DenseBytes int // clocks sent with an entry for the server and every client
+	SparseBytes int // clocks sent as a map of the entries they have seen
+	DiffBytes int
+	DiffEntries int // entries carried by the compressed clocks
+}
+
+// Every round a random client ticks its clock and sends a message to the server, which merges it, ticks once
+// per forward and sends it on to every other client like the demo does. Each clock is encoded as a dense
+// vector, as a sparse map and as a difference over the link it is sent on, which is FIFO in the simulation.
+func simulate(nodes int, rounds int, rng *rand.Rand) result {
+	var r result
+	serverClock := make(client.VectorClock)
+	clocks := make([]client.VectorClock, nodes)
+	toServer := make([]*client.DiffEncoder, nodes)
+	toClient := make([]*client.DiffEncoder, nodes)
+	for i := range nodes {
+		clocks[i] = make(client.VectorClock)
+		toServer[i] = client.NewDiffEncoder()
+		toClient[i] = client.NewDiffEncoder()
+	}
+
+	send := func(encoder *client.DiffEncoder, clock client.VectorClock) {
+		message := encoder.Encode(client.Message{Clock: clock})
+		r.Messages += 1
+		r.DenseBytes += denseSize(clock, nodes)
+		r.SparseBytes += message.Clock.EncodedSize()
+		r.DiffBytes += message.Diff.EncodedSize() + binary.PutUvarint(make([]byte, binary.MaxVarintLen64), uint64(message.DiffSeq))
+		r.DiffEntries += len(message.Diff)
+	}
+
+	for range rounds {
+		sender := rng.Intn(nodes)
+		clocks[sender][sender] += 1
+		send(toServer[sender], clocks[sender])
+		serverClock = client.VectorMAX(serverClock, clocks[sender])
+		serverClock[server.ServerId] += 1
+
+		for i := range nodes {
+			if i == sender {
+				continue
+			}
+			serverClock[server.ServerId] += 1
+			send(toClient[i], serverClock)
+			clocks[i] = client.VectorMAX(clocks[i], serverClock)
+			clocks[i][i] += 1
+		}
+	}
+	return r
+}
+
+// bytes of a clock sent as a dense vector with an entry for the server followed by one for every client in id order,
+// so only the number of entries and the values are encoded
+func denseSize(clock client.VectorClock, nodes int) int {
+	buffer := make([]byte, binary.MaxVarintLen64)
+	size := binary.PutUvarint(buffer, uint64(nodes + 1))
+	size += binary.PutUvarint(buffer, uint64(clock[server.ServerId]))
+	for id := range nodes {
+		size += binary.PutUvarint(buffer, uint64(clock[id]))
+	}
+	return size
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"vector-clock/lattice"
+	"vector-clock/trace"
+)
+
+// Builds the lattice of consistent global states of an event log written with -log
+// and evaluates possibly and definitely predicates over it
+func main() {
+	possibly := make([]string, 0)
+	definitely := make([]string, 0)
+	flag.Func("possibly", "predicate that holds in at least one consistent global state, can be given more than once", func(value string) error {
+		possibly = append(possibly, value)
+		return nil
+	})
+	flag.Func("definitely", "predicate that every run allowed by the trace goes through, can be given more than once", func(value string) error {
+		definitely = append(definitely, value)
+		return nil
+	})
+	maxStates := flag.Int("max-states", 1000000, "largest number of consistent global states to explore")
+	dotFile := flag.String("dot", "", "file to write a Graphviz graph of the lattice to")
+	prefix := flag.Int("events", 0, "only analyze the first n events of the log, 0 analyzes all of them")
+	flag.Usage = func() {
+		fmt.Println("Usage: lattice [-possibly predicate] [-definitely predicate] [-events n] [-max-states n] [-dot file] events.jsonl")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Println("Error occurred while opening the event log: ", err)
+		os.Exit(2)
+	}
+	defer file.Close()
+
+	events, err := trace.ReadEvents(file)
+	if err != nil {
+		fmt.Println("Error occurred while reading the event log: ", err)
+		os.Exit(2)
+	}
+
+	// every message is logged as sent before it is logged as received, so any prefix of the log is a consistent cut
+	if *prefix > 0 && *prefix < len(events) {
+		events = events[:*prefix]
+	}
+
+	l, err := lattice.Build(events, *maxStates)
+	if err != nil {
+		fmt.Println("Error occurred while building the lattice: ", err)
+		os.Exit(2)
+	}
+	possiblyPredicates := parse(l, possibly)
+	definitelyPredicates := parse(l, definitely)
+	fmt.Printf("[LATTICE] %d nodes, %d consistent global states in %d levels, the widest level has %d states\n", len(l.Nodes), l.Size(), len(l.Levels), l.Width())
+
+	for _, predicate := range possiblyPredicates {
+		if cut, ok := l.Possibly(predicate); ok {
+			fmt.Printf("[POSSIBLY] %s: true, first in %s\n", predicate.Expression, l.Describe(cut))
+		} else {
+			fmt.Printf("[POSSIBLY] %s: false\n", predicate.Expression)
+		}
+	}
+	for _, predicate := range definitelyPredicates {
+		fmt.Printf("[DEFINITELY] %s: %t\n", predicate.Expression, l.Definitely(predicate))
+	}
+
+	if *dotFile != "" {
+		dot, err := os.Create(*dotFile)
+		if err == nil {
+			err = l.WriteDOT(dot)
+			dot.Close()
+		}
+		if err != nil {
+			fmt.Printf("Error occurred while writing %s: %s\n", *dotFile, err)
+			os.Exit(2)
+		}
+		fmt.Printf("[LATTICE] Written to %s\n", *dotFile)
+	}
+}
+
+// parses the predicates given on the command line, exiting if one of them is not valid
+func parse(l *lattice.Lattice, expressions []string) []*lattice.Predicate {
+	predicates := make([]*lattice.Predicate, len(expressions))
+	for i, expression := range expressions {
+		predicate, err := l.Parse(expression)
+		if err != nil {
+			fmt.Printf("Error occurred while parsing %q: %s\n", expression, err)
+			os.Exit(2)
+		}
+		predicates[i] = predicate
+	}
+	return predicates
+}
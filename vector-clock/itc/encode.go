@@ -0,0 +1,50 @@
+package itc
+
+// Number of bytes of the stamp in the compact binary encoding of the paper. Ids take 2 bits per node
+// and 3 bits per leaf, event trees take a few bits per node and counts use a variable number of bits
+// that grows with their value.
+func (s Stamp) EncodedSize() int {
+	bits := idBits(s.Id) + eventBits(s.Events)
+	return (bits + 7) / 8
+}
+
+func idBits(i *Id) int {
+	switch {
+	case i.isLeaf():
+		return 3
+	case i.Left.isZero():
+		return 2 + idBits(i.Right)
+	case i.Right.isZero():
+		return 2 + idBits(i.Left)
+	default:
+		return 2 + idBits(i.Left) + idBits(i.Right)
+	}
+}
+
+func eventBits(e *Event) int {
+	if e.isLeaf() {
+		return 1 + countBits(e.N, 2)
+	}
+
+	// a zero leaf on one side is left out of the encoding
+	children := 0
+	if !e.Left.isLeaf() || e.Left.N != 0 {
+		children += eventBits(e.Left)
+	}
+	if !e.Right.isLeaf() || e.Right.N != 0 {
+		children += eventBits(e.Right)
+	}
+	if e.N == 0 {
+		return 3 + children
+	}
+	return 4 + eventBits(leaf(e.N)) + children
+}
+
+// Bits of a count encoded with a prefix bit per group: counts below 2^width take 1 + width bits,
+// larger ones move on to the next group with one more bit
+func countBits(n int, width int) int {
+	if n < 1 << width {
+		return 1 + width
+	}
+	return 1 + countBits(n - (1 << width), width + 1)
+}
@@ -0,0 +1,154 @@
+package itc
+
+import "fmt"
+
+// Event tree of a stamp: how many events have been registered over each part of the interval.
+// A leaf holds a count, a node holds a count that is added to both of its halves.
+type Event struct {
+	N int
+	Left, Right *Event
+}
+
+// depth penalty of turning a leaf into a node when growing, so that any fill or inflation of an existing
+// node is preferred over making the tree deeper
+const growCost = 1000
+
+func (e *Event) isLeaf() bool {
+	return e.Left == nil
+}
+
+func leaf(n int) *Event {
+	return &Event{N: n}
+}
+
+// Normalizing a node: equal leaves are folded into their parent and the common minimum of the halves is
+// lifted into the parent, so equal causal histories always have the same tree
+func newEventNode(n int, left, right *Event) *Event {
+	if left.isLeaf() && right.isLeaf() && left.N == right.N {
+		return leaf(n + left.N)
+	}
+	m := min(minimum(left), minimum(right))
+	return &Event{N: n + m, Left: sink(left, m), Right: sink(right, m)}
+}
+
+func lift(e *Event, m int) *Event {
+	return &Event{N: e.N + m, Left: e.Left, Right: e.Right}
+}
+
+func sink(e *Event, m int) *Event {
+	return &Event{N: e.N - m, Left: e.Left, Right: e.Right}
+}
+
+// Smallest count over the interval of the tree
+func minimum(e *Event) int {
+	if e.isLeaf() {
+		return e.N
+	}
+	return e.N + min(minimum(e.Left), minimum(e.Right))
+}
+
+// Largest count over the interval of the tree
+func maximum(e *Event) int {
+	if e.isLeaf() {
+		return e.N
+	}
+	return e.N + max(maximum(e.Left), maximum(e.Right))
+}
+
+// Checking if the first tree is below or equal to the second one over the whole interval
+func leq(a, b *Event) bool {
+	if a.isLeaf() {
+		return a.N <= b.N
+	}
+	if b.isLeaf() {
+		return a.N <= b.N && leq(lift(a.Left, a.N), b) && leq(lift(a.Right, a.N), b)
+	}
+	return a.N <= b.N && leq(lift(a.Left, a.N), lift(b.Left, b.N)) && leq(lift(a.Right, a.N), lift(b.Right, b.N))
+}
+
+// Smallest tree that is above or equal to both trees
+func joinEvents(a, b *Event) *Event {
+	if a.isLeaf() && b.isLeaf() {
+		return leaf(max(a.N, b.N))
+	}
+	if a.isLeaf() {
+		return joinEvents(&Event{N: a.N, Left: leaf(0), Right: leaf(0)}, b)
+	}
+	if b.isLeaf() {
+		return joinEvents(a, &Event{N: b.N, Left: leaf(0), Right: leaf(0)})
+	}
+	if a.N > b.N {
+		return joinEvents(b, a)
+	}
+	difference := b.N - a.N
+	return newEventNode(a.N, joinEvents(a.Left, lift(b.Left, difference)), joinEvents(a.Right, lift(b.Right, difference)))
+}
+
+// Raising the counts in the parts of the interval the id owns as far as possible without adding an event,
+// which simplifies the tree
+func fill(i *Id, e *Event) *Event {
+	switch {
+	case i.isZero():
+		return e
+	case i.isOne():
+		return leaf(maximum(e))
+	case e.isLeaf():
+		return e
+	case i.Left.isOne():
+		right := fill(i.Right, e.Right)
+		return newEventNode(e.N, leaf(max(maximum(e.Left), minimum(right))), right)
+	case i.Right.isOne():
+		left := fill(i.Left, e.Left)
+		return newEventNode(e.N, left, leaf(max(maximum(e.Right), minimum(left))))
+	default:
+		return newEventNode(e.N, fill(i.Left, e.Left), fill(i.Right, e.Right))
+	}
+}
+
+// Adding an event in a part of the interval the id owns, choosing the part that keeps the tree smallest.
+// Returns the new tree along with the cost of the change.
+func grow(i *Id, e *Event) (*Event, int) {
+	if i.isZero() {
+		return e, growCost * growCost // an anonymous stamp cannot register events
+	}
+	if i.isOne() && !e.isLeaf() {
+		return leaf(maximum(e) + 1), 0
+	}
+	if e.isLeaf() {
+		if i.isOne() {
+			return leaf(e.N + 1), 0
+		}
+		grown, cost := grow(i, &Event{N: e.N, Left: leaf(0), Right: leaf(0)})
+		return grown, cost + growCost
+	}
+
+	switch {
+	case i.Left.isZero():
+		right, cost := grow(i.Right, e.Right)
+		return &Event{N: e.N, Left: e.Left, Right: right}, cost + 1
+	case i.Right.isZero():
+		left, cost := grow(i.Left, e.Left)
+		return &Event{N: e.N, Left: left, Right: e.Right}, cost + 1
+	default:
+		left, leftCost := grow(i.Left, e.Left)
+		right, rightCost := grow(i.Right, e.Right)
+		if leftCost < rightCost {
+			return &Event{N: e.N, Left: left, Right: e.Right}, leftCost + 1
+		}
+		return &Event{N: e.N, Left: e.Left, Right: right}, rightCost + 1
+	}
+}
+
+func equalEvents(a, b *Event) bool {
+	if a.isLeaf() || b.isLeaf() {
+		return a.isLeaf() && b.isLeaf() && a.N == b.N
+	}
+	return a.N == b.N && equalEvents(a.Left, b.Left) && equalEvents(a.Right, b.Right)
+}
+
+func (e *Event) String() string {
+	if e.isLeaf() {
+		return fmt.Sprint(e.N)
+	}
+	return fmt.Sprintf("(%d, %v, %v)", e.N, e.Left, e.Right)
+}
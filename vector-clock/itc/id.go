@@ -0,0 +1,79 @@
+package itc
+
+import "fmt"
+
+// Id tree of a stamp: the part of the interval [0, 1) that its owner may register events in.
+// A leaf owns the whole interval below it if its value is 1 and none of it if it is 0,
+// a node splits its interval into two halves.
+type Id struct {
+	Value int // 0 or 1, only used by leaves
+	Left, Right *Id
+}
+
+var (
+	zeroId = &Id{Value: 0}
+	oneId = &Id{Value: 1}
+)
+
+func (i *Id) isLeaf() bool {
+	return i.Left == nil
+}
+
+func (i *Id) isZero() bool {
+	return i.isLeaf() && i.Value == 0
+}
+
+func (i *Id) isOne() bool {
+	return i.isLeaf() && i.Value == 1
+}
+
+// node whose halves are the same leaf is replaced by the leaf
+func newIdNode(left, right *Id) *Id {
+	if left.isZero() && right.isZero() {
+		return zeroId
+	}
+	if left.isOne() && right.isOne() {
+		return oneId
+	}
+	return &Id{Left: left, Right: right}
+}
+
+// Splitting an id into two disjoint ids that together own the same interval
+func split(i *Id) (*Id, *Id) {
+	switch {
+	case i.isZero():
+		return zeroId, zeroId
+	case i.isOne():
+		return &Id{Left: oneId, Right: zeroId}, &Id{Left: zeroId, Right: oneId}
+	case i.Left.isZero():
+		a, b := split(i.Right)
+		return &Id{Left: zeroId, Right: a}, &Id{Left: zeroId, Right: b}
+	case i.Right.isZero():
+		a, b := split(i.Left)
+		return &Id{Left: a, Right: zeroId}, &Id{Left: b, Right: zeroId}
+	default:
+		return &Id{Left: i.Left, Right: zeroId}, &Id{Left: zeroId, Right: i.Right}
+	}
+}
+
+// Merging two disjoint ids into the id that owns both of their intervals
+func sum(a, b *Id) *Id {
+	if a.isZero() {
+		return b
+	}
+	if b.isZero() {
+		return a
+	}
+	if a.isLeaf() || b.isLeaf() {
+		// two ids owning the same part of the interval is a bug in the caller, the result owns all of it
+		return oneId
+	}
+	return newIdNode(sum(a.Left, b.Left), sum(a.Right, b.Right))
+}
+
+func (i *Id) String() string {
+	if i.isLeaf() {
+		return fmt.Sprint(i.Value)
+	}
+	return fmt.Sprintf("(%v, %v)", i.Left, i.Right)
+}
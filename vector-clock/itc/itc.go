@@ -0,0 +1,84 @@
+package itc
+
+import "fmt"
+
+// Results of comparing two stamps
+const (
+	BEFORE = "before" // the first stamp happened before the second one
+	AFTER = "after" // the second stamp happened before the first one
+	EQUAL = "equal"
+	CONCURRENT = "concurrent"
+)
+
+// Stamp of an Interval Tree Clock (Almeida, Baquero and Fonte): an id telling which part of the interval [0, 1)
+// the participant owns, and an event tree recording the causal history. Participants are created by forking
+// a stamp and retired by joining it into another one, so they need no global ids and nothing is left behind
+// when they go. Stamps are immutable, so they can be shared between goroutines and put in messages.
+type Stamp struct {
+	Id *Id
+	Events *Event
+}
+
+// Stamp of the first participant, which owns the whole interval
+func Seed() Stamp {
+	return Stamp{Id: oneId, Events: leaf(0)}
+}
+
+// Splits the stamp into two stamps with the same causal history and disjoint ids,
+// one for the participant and one for the participant it creates
+func (s Stamp) Fork() (Stamp, Stamp) {
+	a, b := split(s.Id)
+	return Stamp{Id: a, Events: s.Events}, Stamp{Id: b, Events: s.Events}
+}
+
+// Anonymous copy of the stamp with its causal history only, to be sent in a message
+func (s Stamp) Peek() Stamp {
+	return Stamp{Id: zeroId, Events: s.Events}
+}
+
+// Registers an event of the participant. An anonymous stamp cannot register events, so it is returned unchanged.
+func (s Stamp) Event() Stamp {
+	if s.IsAnonymous() {
+		return s
+	}
+
+	if filled := fill(s.Id, s.Events); !equalEvents(filled, s.Events) {
+		return Stamp{Id: s.Id, Events: filled}
+	}
+	grown, _ := grow(s.Id, s.Events)
+	return Stamp{Id: s.Id, Events: grown}
+}
+
+// Merges two stamps: the result owns both ids and knows both causal histories.
+// Joining a peeked stamp is how a participant receives a message, joining a full stamp retires its owner.
+func Join(a, b Stamp) Stamp {
+	return Stamp{Id: sum(a.Id, b.Id), Events: joinEvents(a.Events, b.Events)}
+}
+
+func (s Stamp) IsAnonymous() bool {
+	return s.Id.isZero()
+}
+
+// Checking if the causal history of the first stamp is included in the second one
+func Leq(a, b Stamp) bool {
+	return leq(a.Events, b.Events)
+}
+
+// Comparing the causal histories of two stamps, returning BEFORE, AFTER, EQUAL or CONCURRENT
+func Compare(a, b Stamp) string {
+	before, after := Leq(a, b), Leq(b, a)
+	switch {
+	case before && after:
+		return EQUAL
+	case before:
+		return BEFORE
+	case after:
+		return AFTER
+	default:
+		return CONCURRENT
+	}
+}
+
+func (s Stamp) String() string {
+	return fmt.Sprintf("(%v, %v)", s.Id, s.Events)
+}
@@ -0,0 +1,160 @@
+package itc
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// a run like the example of the paper: a seed forked in two, both halves register events, one of them learns of the
+// other's events and the two are joined back into a single stamp
+func TestForkEventJoin(t *testing.T) {
+	seed := Seed()
+	if got := seed.String(); got != "(1, 0)" {
+		t.Fatalf("seed is %s, want (1, 0)", got)
+	}
+
+	a, b := seed.Fork()
+	if a.Id.String() != "(1, 0)" || b.Id.String() != "(0, 1)" {
+		t.Fatalf("fork gave ids %v and %v, want (1, 0) and (0, 1)", a.Id, b.Id)
+	}
+	left, _ := a.Fork()
+	if got := left.Id.String(); got != "((1, 0), 0)" {
+		t.Fatalf("forking (1, 0) kept %s, want ((1, 0), 0)", got)
+	}
+
+	a = a.Event()
+	if got := a.Events.String(); got != "(0, 1, 0)" {
+		t.Fatalf("event on the left half gave %s, want (0, 1, 0)", got)
+	}
+	if got := a.Event().Events.String(); got != "(0, 2, 0)" {
+		t.Fatalf("second event on the left half gave %s, want (0, 2, 0)", got)
+	}
+	b = b.Event().Event()
+	if got := b.Events.String(); got != "(0, 0, 2)" {
+		t.Fatalf("two events on the right half gave %s, want (0, 0, 2)", got)
+	}
+
+	// joining the history of b gives (1, 0, 1), which the next event fills up to a single leaf instead of growing
+	received := Join(a, b.Peek())
+	if got := received.String(); got != "((1, 0), (1, 0, 1))" {
+		t.Fatalf("receiving from b gave %s, want ((1, 0), (1, 0, 1))", got)
+	}
+	if got := received.Event().String(); got != "((1, 0), 2)" {
+		t.Fatalf("event after receiving gave %s, want ((1, 0), 2)", got)
+	}
+
+	joined := Join(a, b)
+	if got := joined.String(); got != "(1, (1, 0, 1))" {
+		t.Fatalf("joining both halves gave %s, want (1, (1, 0, 1))", got)
+	}
+	// the joined stamp owns the whole interval, so filling it up to its maximum already registers an event
+	if got := joined.Event().String(); got != "(1, 2)" {
+		t.Fatalf("event after joining gave %s, want (1, 2)", got)
+	}
+}
+
+func TestAnonymousStampCannotRegisterEvents(t *testing.T) {
+	a, _ := Seed().Fork()
+	peek := a.Event().Peek()
+	if !peek.IsAnonymous() {
+		t.Fatalf("peeked stamp %v is not anonymous", peek)
+	}
+	if got := peek.Event(); got.String() != peek.String() {
+		t.Fatalf("event on an anonymous stamp gave %v, want it unchanged", got)
+	}
+}
+
+func TestLeqAndCompare(t *testing.T) {
+	a, b := Seed().Fork()
+	a1 := a.Event()
+	b1 := b.Event()
+	received := Join(b1, a1.Peek()).Event()
+
+	tests := []struct {
+		name string
+		x, y Stamp
+		leq bool
+		relation string
+	}{
+		{"fork halves", a, b, true, EQUAL},
+		{"event after fork", a, a1, true, BEFORE},
+		{"later event", a1, a, false, AFTER},
+		{"events of both halves", a1, b1, false, CONCURRENT},
+		{"sent before received", a1, received, true, BEFORE},
+		{"received after sent", received, a1, false, AFTER},
+		{"peek keeps the history", a1.Peek(), a1, true, EQUAL},
+	}
+	for _, test := range tests {
+		if got := Leq(test.x, test.y); got != test.leq {
+			t.Errorf("%s: Leq(%v, %v) = %v, want %v", test.name, test.x, test.y, got, test.leq)
+		}
+		if got := Compare(test.x, test.y); got != test.relation {
+			t.Errorf("%s: Compare(%v, %v) = %s, want %s", test.name, test.x, test.y, got, test.relation)
+		}
+	}
+}
+
+// participant of a random run, along with the events it has seen
+type participant struct {
+	stamp Stamp
+	seen map[int]bool
+}
+
+// Leq has to give the inclusion of the events two participants have seen, whatever the forks, events,
+// receives and retirements that led to their stamps
+func TestLeqMatchesCausalHistory(t *testing.T) {
+	for seed := int64(0); seed < 500; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		participants := []*participant{{Seed(), map[int]bool{}}}
+		events := 0
+		for step := range 60 {
+			p := participants[rng.Intn(len(participants))]
+			switch rng.Intn(5) {
+			case 0, 1: // event
+				events += 1
+				p.stamp = p.stamp.Event()
+				p.seen[events] = true
+			case 2: // receive from a random participant
+				from := participants[rng.Intn(len(participants))]
+				p.stamp = Join(p.stamp, from.stamp.Peek())
+				for event := range from.seen {
+					p.seen[event] = true
+				}
+			case 3: // fork
+				if len(participants) == 8 {
+					continue
+				}
+				kept, forked := p.stamp.Fork()
+				p.stamp = kept
+				seen := make(map[int]bool)
+				for event := range p.seen {
+					seen[event] = true
+				}
+				participants = append(participants, &participant{forked, seen})
+			case 4: // retire a random participant into p
+				i := rng.Intn(len(participants))
+				if participants[i] == p {
+					continue
+				}
+				retired := participants[i]
+				participants = append(participants[:i], participants[i + 1:]...)
+				p.stamp = Join(p.stamp, retired.stamp)
+				for event := range retired.seen {
+					p.seen[event] = true
+				}
+			}
+
+			for _, x := range participants {
+				for _, y := range participants {
+					included := true
+					for event := range x.seen {
+						included = included && y.seen[event]
+					}
+					if Leq(x.stamp, y.stamp) != included {
+						t.Fatalf("seed %d, step %d: Leq(%v, %v) = %v, want %v", seed, step, x.stamp, y.stamp, !included, included)
+					}
+				}
+			}
+		}
+	}
+}
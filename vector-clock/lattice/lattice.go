@@ -0,0 +1,174 @@
+package lattice
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"vector-clock/trace"
+)
+
+// Cut is a global state of a run: the number of events of each node that have happened, in the order of Lattice.Nodes
+type Cut []int
+
+// Lattice of the consistent cuts of a run. A cut is consistent if every event in it has all of its causal
+// predecessors in it too, and it leads to every cut that has one more event of some node and is still consistent.
+type Lattice struct {
+	Nodes []string
+	Events [][]trace.Event // steps of each node in the order they were recorded
+	Levels [][]Cut // consistent cuts by the number of events they contain, the first level is the initial state
+	ids []int // vector clock entry of each node
+}
+
+// Builds the lattice of a run from its events, giving up once it has more than maxStates cuts
+func Build(events []trace.Event, maxStates int) (*Lattice, error) {
+	l := &Lattice{Nodes: trace.Nodes(events)}
+	l.Events = make([][]trace.Event, len(l.Nodes))
+	l.ids = make([]int, len(l.Nodes))
+	for n, node := range l.Nodes {
+		l.ids[n] = trace.NodeId(node)
+	}
+	for _, event := range events {
+		// a drop is not a step of the node, it only shows that the message never arrived
+		if event.Type == trace.DROP {
+			continue
+		}
+		for n, node := range l.Nodes {
+			if node == event.Node {
+				l.Events[n] = append(l.Events[n], event)
+			}
+		}
+	}
+
+	states := 1
+	level := []Cut{make(Cut, len(l.Nodes))}
+	for len(level) > 0 {
+		l.Levels = append(l.Levels, level)
+
+		seen := make(map[string]bool)
+		next := make([]Cut, 0)
+		for _, cut := range level {
+			for _, successor := range l.Successors(cut) {
+				if key := successor.key(); !seen[key] {
+					seen[key] = true
+					next = append(next, successor)
+				}
+			}
+		}
+
+		states += len(next)
+		if states > maxStates {
+			return nil, fmt.Errorf("the lattice has more than %d consistent cuts", maxStates)
+		}
+		level = next
+	}
+
+	if !l.isFinal(l.Levels[len(l.Levels) - 1][0]) {
+		return nil, fmt.Errorf("the final state cannot be reached, a message is received with a clock it was not sent with")
+	}
+	return l, nil
+}
+
+// Consistent cuts that have one more event than the cut
+func (l *Lattice) Successors(cut Cut) []Cut {
+	successors := make([]Cut, 0, len(cut))
+	for n := range cut {
+		if l.enabled(cut, n) {
+			successor := append(Cut{}, cut...)
+			successor[n] += 1
+			successors = append(successors, successor)
+		}
+	}
+	return successors
+}
+
+// Checking if the next event of a node can be added to the cut: every event of another node that it
+// depends on, as shown by its vector clock, has to be in the cut already
+func (l *Lattice) enabled(cut Cut, n int) bool {
+	if cut[n] == len(l.Events[n]) {
+		return false
+	}
+
+	event := l.Events[n][cut[n]]
+	for m := range cut {
+		if m != n && event.Clock[l.ids[m]] > l.frontier(cut, m) {
+			return false
+		}
+	}
+	return true
+}
+
+// Entry of a node in its own vector clock at the last event of the node in the cut
+func (l *Lattice) frontier(cut Cut, n int) int {
+	if len(l.Events[n]) == 0 {
+		return 0
+	}
+	if cut[n] == 0 {
+		return l.Events[n][0].ClockBefore[l.ids[n]]
+	}
+	return l.Events[n][cut[n] - 1].Clock[l.ids[n]]
+}
+
+// Number of consistent cuts in the lattice
+func (l *Lattice) Size() int {
+	size := 0
+	for _, level := range l.Levels {
+		size += len(level)
+	}
+	return size
+}
+
+// Number of cuts in the widest level, which bounds how many global states have to be kept at once
+func (l *Lattice) Width() int {
+	width := 0
+	for _, level := range l.Levels {
+		width = max(width, len(level))
+	}
+	return width
+}
+
+func (l *Lattice) Initial() Cut {
+	return l.Levels[0][0]
+}
+
+func (l *Lattice) isFinal(cut Cut) bool {
+	for n := range cut {
+		if cut[n] != len(l.Events[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Description of a cut with the number of events of each node, such as {server:4 client-0:2 client-1:3}
+func (l *Lattice) Describe(cut Cut) string {
+	parts := make([]string, len(cut))
+	for n, count := range cut {
+		parts[n] = fmt.Sprintf("%s:%d", l.Nodes[n], count)
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+// Writes the lattice as a Graphviz graph with the initial state at the top
+func (l *Lattice) WriteDOT(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "digraph lattice {\n  node [shape=box, fontsize=10];"); err != nil {
+		return err
+	}
+	for _, level := range l.Levels {
+		for _, cut := range level {
+			if _, err := fmt.Fprintf(w, "  %q;\n", l.Describe(cut)); err != nil {
+				return err
+			}
+			for _, successor := range l.Successors(cut) {
+				if _, err := fmt.Fprintf(w, "  %q -> %q;\n", l.Describe(cut), l.Describe(successor)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+func (c Cut) key() string {
+	return fmt.Sprint([]int(c))
+}
@@ -0,0 +1,48 @@
+package lattice
+
+import (
+	"testing"
+	"vector-clock/trace"
+)
+
+// a message of client-0 relayed by server-0 to server-1, which delivers it to client-1000 of its region. Each
+// server has its own entry in the vector clocks, -1 and -2, so server-1 can only receive after client-0 sent
+func TestBuildFederatedTrace(t *testing.T) {
+	events := []trace.Event{
+		{Node: "client-0", Type: trace.SEND, Peer: "server-0", MessageId: "m1", ClockBefore: map[int]int{}, Clock: map[int]int{0: 1}},
+		{Node: "server-0", Type: trace.RECEIVE, Peer: "client-0", MessageId: "m1", ClockBefore: map[int]int{}, Clock: map[int]int{-1: 1, 0: 1}},
+		{Node: "server-0", Type: trace.SEND, Peer: "server-1", MessageId: "m2", ClockBefore: map[int]int{-1: 1, 0: 1}, Clock: map[int]int{-1: 2, 0: 1}},
+		{Node: "server-1", Type: trace.INTERNAL, ClockBefore: map[int]int{}, Clock: map[int]int{-2: 1}},
+		{Node: "server-1", Type: trace.RECEIVE, Peer: "server-0", MessageId: "m2", ClockBefore: map[int]int{-2: 1}, Clock: map[int]int{-2: 2, -1: 2, 0: 1}},
+		{Node: "server-1", Type: trace.SEND, Peer: "client-1000", MessageId: "m3", ClockBefore: map[int]int{-2: 2, -1: 2, 0: 1}, Clock: map[int]int{-2: 3, -1: 2, 0: 1}},
+		{Node: "client-1000", Type: trace.RECEIVE, Peer: "server-1", MessageId: "m3", ClockBefore: map[int]int{}, Clock: map[int]int{1000: 1, -2: 3, -1: 2, 0: 1}},
+	}
+
+	l, err := Build(events, 1000)
+	if err != nil {
+		t.Fatalf("building the lattice failed: %v", err)
+	}
+	// the internal event of server-1 is concurrent with the 4 cuts of the first three events, the rest is a chain
+	if got := l.Size(); got != 11 {
+		t.Errorf("the lattice has %d consistent cuts, want 11", got)
+	}
+
+	tests := []struct {
+		expression string
+		possibly bool
+	}{
+		{"events(server-1) == 1 && sent(client-0) == 0", true},
+		{"received(server-1) == 1 && sent(client-0) == 0", false},
+		{"received(server-1) == 1 && received(server-0) == 0", false},
+		{"received(client-1000) == 1 && sent(server-0) == 1", true},
+	}
+	for _, test := range tests {
+		predicate, err := l.Parse(test.expression)
+		if err != nil {
+			t.Fatalf("parsing %q failed: %v", test.expression, err)
+		}
+		if _, got := l.Possibly(predicate); got != test.possibly {
+			t.Errorf("possibly %s is %t, want %t", test.expression, got, test.possibly)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package lattice
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"vector-clock/trace"
+)
+
+// Predicate over the global state of a cut, such as sent(client-0) == sent(client-1) && received(server) > 3
+type Predicate struct {
+	Expression string
+	holds condition
+}
+
+// Functions that can be compared in a predicate, each of them counts events of a node in the cut
+var counters = map[string]func(event trace.Event) bool{
+	"sent": func(event trace.Event) bool { return event.Type == trace.SEND },
+	"received": func(event trace.Event) bool { return event.Type == trace.RECEIVE },
+	"events": func(event trace.Event) bool { return true },
+}
+
+// Checking if the predicate holds in at least one consistent cut, returning the first one found.
+// The cuts are visited level by level, so the cut returned has as few events as possible.
+func (l *Lattice) Possibly(p *Predicate) (Cut, bool) {
+	for _, level := range l.Levels {
+		for _, cut := range level {
+			if p.holds(l, cut) {
+				return cut, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Checking if the predicate holds in some cut of every run that the trace allows, i.e. every path
+// through the lattice from the initial state to the final state goes through a cut where it holds.
+// It does not hold if the final state can be reached through cuts where the predicate is false.
+func (l *Lattice) Definitely(p *Predicate) bool {
+	if p.holds(l, l.Initial()) {
+		return true
+	}
+
+	level := []Cut{l.Initial()}
+	for len(level) > 0 {
+		seen := make(map[string]bool)
+		next := make([]Cut, 0)
+		for _, cut := range level {
+			if l.isFinal(cut) {
+				return false
+			}
+			for _, successor := range l.Successors(cut) {
+				if key := successor.key(); !seen[key] && !p.holds(l, successor) {
+					seen[key] = true
+					next = append(next, successor)
+				}
+			}
+		}
+		level = next
+	}
+	return true
+}
+
+// Parses a predicate made of comparisons of sums of counters and numbers, such as sent(client-0) >= received(client-1) + 2,
+// joined by && and || and grouped with parentheses. && binds tighter than ||.
+// The counters are sent, received and events, each taking the name of a node in the trace.
+func (l *Lattice) Parse(expression string) (*Predicate, error) {
+	p := &parser{lattice: l, tokens: tokenize(expression)}
+	holds, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.position < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in predicate", p.tokens[p.position])
+	}
+	return &Predicate{Expression: expression, holds: holds}, nil
+}
+
+type parser struct {
+	lattice *Lattice
+	tokens []string
+	position int
+}
+
+type condition func(l *Lattice, cut Cut) bool
+type term func(l *Lattice, cut Cut) int
+
+func (p *parser) or() (condition, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		a, b := left, right
+		left = func(l *Lattice, cut Cut) bool { return a(l, cut) || b(l, cut) }
+	}
+	return left, nil
+}
+
+func (p *parser) and() (condition, error) {
+	left, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		a, b := left, right
+		left = func(l *Lattice, cut Cut) bool { return a(l, cut) && b(l, cut) }
+	}
+	return left, nil
+}
+
+func (p *parser) comparison() (condition, error) {
+	if p.accept("(") {
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing ) in predicate")
+		}
+		return inner, nil
+	}
+
+	left, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	operator := p.next()
+	right, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+
+	var compare func(a, b int) bool
+	switch operator {
+	case "==":
+		compare = func(a, b int) bool { return a == b }
+	case "!=":
+		compare = func(a, b int) bool { return a != b }
+	case "<":
+		compare = func(a, b int) bool { return a < b }
+	case "<=":
+		compare = func(a, b int) bool { return a <= b }
+	case ">":
+		compare = func(a, b int) bool { return a > b }
+	case ">=":
+		compare = func(a, b int) bool { return a >= b }
+	default:
+		return nil, fmt.Errorf("expected a comparison operator in predicate, found %q", operator)
+	}
+	return func(l *Lattice, cut Cut) bool { return compare(left(l, cut), right(l, cut)) }, nil
+}
+
+func (p *parser) sum() (term, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		sign := 0
+		if p.accept("+") {
+			sign = 1
+		} else if p.accept("-") {
+			sign = -1
+		} else {
+			return left, nil
+		}
+
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		a, b := left, right
+		left = func(l *Lattice, cut Cut) int { return a(l, cut) + sign * b(l, cut) }
+	}
+}
+
+func (p *parser) term() (term, error) {
+	token := p.next()
+	if value, err := strconv.Atoi(token); err == nil {
+		return func(l *Lattice, cut Cut) int { return value }, nil
+	}
+
+	counter, ok := counters[token]
+	if !ok {
+		return nil, fmt.Errorf("expected a number or one of sent, received and events in predicate, found %q", token)
+	}
+	if !p.accept("(") {
+		return nil, fmt.Errorf("%s needs the name of a node in predicate", token)
+	}
+	name := p.next()
+	node := slices.Index(p.lattice.Nodes, name)
+	if node == -1 {
+		return nil, fmt.Errorf("node %q is not in the trace", name)
+	}
+	if !p.accept(")") {
+		return nil, fmt.Errorf("missing ) after %s(%s in predicate", token, name)
+	}
+
+	// counting the events of every prefix of the node once, so evaluating the term is a lookup
+	counts := make([]int, len(p.lattice.Events[node]) + 1)
+	for i, event := range p.lattice.Events[node] {
+		counts[i + 1] = counts[i]
+		if counter(event) {
+			counts[i + 1] += 1
+		}
+	}
+	return func(l *Lattice, cut Cut) int { return counts[cut[node]] }, nil
+}
+
+func (p *parser) next() string {
+	if p.position == len(p.tokens) {
+		return "end of input"
+	}
+	p.position += 1
+	return p.tokens[p.position - 1]
+}
+
+func (p *parser) accept(token string) bool {
+	if p.position < len(p.tokens) && p.tokens[p.position] == token {
+		p.position += 1
+		return true
+	}
+	return false
+}
+
+// Splitting a predicate into names, numbers, parentheses and operators
+func tokenize(expression string) []string {
+	tokens := make([]string, 0)
+	for i := 0; i < len(expression); {
+		c := rune(expression[i])
+		switch {
+		case unicode.IsSpace(c):
+			i += 1
+		case c == '(' || c == ')' || c == '+' || c == '-':
+			tokens = append(tokens, string(c))
+			i += 1
+		case unicode.IsDigit(c):
+			end := i
+			for end < len(expression) && unicode.IsDigit(rune(expression[end])) {
+				end += 1
+			}
+			tokens = append(tokens, expression[i:end])
+			i = end
+		case unicode.IsLetter(c):
+			end := i
+			for end < len(expression) && (unicode.IsLetter(rune(expression[end])) || unicode.IsDigit(rune(expression[end])) || expression[end] == '-') {
+				end += 1
+			}
+			tokens = append(tokens, expression[i:end])
+			i = end
+		default:
+			end := i
+			for end < len(expression) && strings.ContainsRune("=!<>&|", rune(expression[end])) {
+				end += 1
+			}
+			if end == i {
+				end += 1
+			}
+			tokens = append(tokens, expression[i:end])
+			i = end
+		}
+	}
+	return tokens
+}
@@ -1,35 +1,740 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"vector-clock/bloom"
 	"vector-clock/client"
+	"vector-clock/clock"
+	"vector-clock/gossip"
+	"vector-clock/itc"
+	"vector-clock/memory"
+	"vector-clock/schedule"
 	"vector-clock/server"
-	"sync"
+	"vector-clock/trace"
 )
 
 const (
-	NumNodes = 10
+	DrainTimeout = 30 * time.Second // how long a bounded run waits for the messages still in flight once the clients have stopped sending
+	SizeReportInterval = 10 * time.Second // how often the sizes of the clocks are reported when interval tree clocks are enabled
 )
 
+var NumNodes = 10 // number of clients of every server, or of peers of the mesh, changed with -clients
+
+// Topologies the clients can be connected in
+const (
+	STAR = "star" // every message goes through the server
+	MESH = "mesh" // the clients push their messages to random peers directly, without a server
+)
+
+// how the clients address their messages and the topics they subscribe to
+type routing struct {
+	mode string
+	topics []string
+}
+
+// how the server queues the messages it forwards to each client
+type queues struct {
+	capacity int
+	policy string
+	report time.Duration // interval between reports of the queue depths, 0 if they are disabled
+}
+
+// when the server crashes and how it recovers
+type crashes struct {
+	interval time.Duration // 0 if the server never crashes
+	random bool
+	downtime time.Duration
+	recovery string
+	checkpointFile string
+	checkpointInterval time.Duration
+}
+
+// what the clocks received are checked against and how many clients corrupt theirs
+type validation struct {
+	limits *client.Validation
+	faulty int // clients whose ids are below this number are faulty
+}
+
+// how often the clients send their messages and when they stop
+type bounds struct {
+	interval time.Duration
+	duration time.Duration // how long the clients send messages for, 0 if they send until the user presses enter
+	messages int // messages every client sends, 0 if they send until the user presses enter
+	stop chan struct{} // closed once the duration is over to make the clients, the peers of the mesh and the churn stop
+	senders sync.WaitGroup // clients still sending messages, peers still starting rumors and the churn while it goes on
+	drained chan struct{} // closed once a bounded run has settled, to make the clients stop reading the causal memory
+}
+
+// keys of the causal memory the clients share and how they access it
+type causalMemory struct {
+	keys []string // empty if the clients do not share a memory
+	reads int
+	eager bool
+	history *memory.History
+}
+
+// clients currently in the system, shared between the churn simulation and the clock size reports
+type participants struct {
+	clients []*client.Client
+	lock sync.Mutex
+}
+
 func main() {
+	configFile := flag.String("config", "", "JSON file setting any of these flags by name, the command line takes precedence over it")
+	flag.IntVar(&NumNodes, "clients", NumNodes, "number of clients of every server, or of peers of the mesh topology")
+	sendInterval := flag.Duration("send-interval", 5 * time.Second, "interval at which every client of the server sends a message, or every client of the mesh starts a rumor")
+	dropRate := flag.Float64("drop-rate", 0.5, "probability that the network drops a message to a client, replaces the drop rate of the default link of the network configuration when given")
+	duration := flag.Duration("duration", 0, "how long the clients send messages before the run ends with a summary, 0 runs until enter is pressed")
+	messages := flag.Int("messages", 0, "number of messages every client sends, or rumors every peer of the mesh starts, before the run ends with a summary, churn makes as many joins and leaves, 0 runs until enter is pressed")
+	churn := flag.Duration("churn", 0, "interval at which a client joins or leaves the system, 0 disables churn")
+	networkFile := flag.String("network", "", "JSON file configuring drops, latency, reordering and duplication per link")
+	seed := flag.Int64("seed", 0, "seed of the network's random number generator, 0 picks one from the current time")
+	dotFile := flag.String("dot", "", "file to write a Graphviz space-time diagram of the run to when it ends")
+	svgFile := flag.String("svg", "", "file to write an SVG space-time diagram of the run to when it ends")
+	shivizFile := flag.String("shiviz", "", "file to write a ShiViz log of the run to when it ends")
+	intervalTreeClocks := flag.Bool("itc", false, "run interval tree clocks alongside the vector clocks, with churn forking new clients off existing ones")
+	matrix := flag.Bool("matrix", false, "track what every client knows with matrix clocks and garbage collect the messages every client has delivered")
+	compress := flag.Bool("compress", false, "send only the entries of the vector clocks that changed since the previous message on each link")
+	logFile := flag.String("log", "", "file to write every event to as a JSON line while the program runs, to be checked with cmd/checktrace")
+	topology := flag.String("topology", STAR, "star to send every message through the server, mesh to gossip between the clients without a server")
+	fanout := flag.Int("fanout", 2, "number of random peers a rumor is pushed to in every round of the mesh topology")
+	rounds := flag.Int("rounds", 3, "number of rounds each client keeps pushing a rumor in the mesh topology")
+	routingMode := flag.String("routing", client.BROADCAST, "how the clients address their messages: broadcast, direct, list, topic or mixed")
+	topics := flag.String("topics", "red,green,blue", "comma-separated topics the clients subscribe to in turn and address messages to")
+	queueCapacity := flag.Int("queue-capacity", 64, "number of messages the server queues for each client before the overflow policy applies")
+	overflow := flag.String("overflow", server.BLOCK, "what a full queue does with the next message: block, drop-oldest or drop-newest")
+	queueReport := flag.Duration("queue-report", 0, "interval at which the depths of the server's queues are reported, 0 disables the reports")
+	crash := flag.Duration("crash", 0, "interval at which the server crashes, 0 disables crashes")
+	crashRandom := flag.Bool("crash-random", false, "crash the server at random points, on average once per crash interval")
+	downtime := flag.Duration("downtime", 3 * time.Second, "how long the server stays down after a crash")
+	recovery := flag.String("recovery", server.CHECKPOINT, "how the server recovers its clock after a crash: checkpoint or max")
+	checkpointFile := flag.String("checkpoint", "checkpoint.json", "file the server checkpoints its clock to")
+	checkpointInterval := flag.Duration("checkpoint-interval", 5 * time.Second, "interval at which the server checkpoints its clock")
+	maxJump := flag.Int("max-jump", 10000, "how far ahead of the receiver's entry an entry of an incoming clock may be, 0 does not bound the jump")
+	quarantine := flag.Duration("quarantine", 0, "how long the messages of a client are rejected after one of its clocks was, 0 for the rest of the run")
+	faulty := flag.Int("faulty", 0, "number of clients that corrupt the clock of some of their messages")
+	servers := flag.Int("servers", 1, "number of regional servers linked in a chain, each with its own clients, that relay the messages of their regions to each other")
+	summary := flag.Bool("summary", false, "print how far every clock grew and how many messages were concurrent when the run ends")
+	recordFile := flag.String("record", "", "file to record the order of every node's steps and the decisions of the network to, to be replayed with -replay")
+	replayFile := flag.String("replay", "", "file to replay a schedule recorded with -record from, with the flags of the recorded run")
+	clockBackend := flag.String("clock-backend", clock.MUTEX, "how the server keeps its clock: mutex or cow to copy it on write")
+	memoryKeys := flag.String("memory", "", "comma-separated keys of a causal memory the clients share, every message carries a write to it. Empty disables the memory")
+	reads := flag.Int("reads", 2, "number of random keys of the causal memory every client reads before each write")
+	eager := flag.Bool("eager", false, "apply the writes to the causal memory as soon as they arrive, without waiting for the writes they depend on")
+	bloomSize := flag.Int("bloom-size", 0, "number of counters of Bloom clocks rebuilt from the trace when the run ends and compared with the vector clocks, 0 disables them")
+	bloomHashes := flag.Int("bloom-hashes", 2, "number of counters of the Bloom clocks every event increments")
+	historyFile := flag.String("history", "", "file to write every read and write of the causal memory to as a JSON line, to be checked with cmd/checkmemory")
+	flag.Parse()
+
+	if *configFile != "" {
+		if err := loadConfig(*configFile); err != nil {
+			fmt.Println("Error occurred while reading the config file: ", err)
+			os.Exit(1)
+		}
+	}
+
+	if *topology != STAR && *topology != MESH {
+		fmt.Printf("The topology must be %s or %s\n", STAR, MESH)
+		os.Exit(1)
+	}
+	if *topology == MESH && (*churn > 0 || *compress || *matrix || *intervalTreeClocks) {
+		fmt.Println("Churn, compression, matrix clocks and interval tree clocks need the server of the star topology")
+		os.Exit(1)
+	}
+	if !slices.Contains(client.RoutingModes, *routingMode) {
+		fmt.Printf("The routing must be one of %s\n", strings.Join(client.RoutingModes, ", "))
+		os.Exit(1)
+	}
+	if *topology == MESH && *routingMode != client.BROADCAST {
+		fmt.Println("Routing needs the server of the star topology")
+		os.Exit(1)
+	}
+	if *matrix && *routingMode != client.BROADCAST {
+		// directed messages are kept out of the history whose garbage collection the matrices track
+		fmt.Println("Matrix clocks only cover broadcasts, so they cannot be combined with selective routing")
+		os.Exit(1)
+	}
+	if *queueCapacity < 1 || !slices.Contains(server.OverflowPolicies, *overflow) {
+		fmt.Printf("The queue capacity must be positive and the overflow policy one of %s\n", strings.Join(server.OverflowPolicies, ", "))
+		os.Exit(1)
+	}
+	routing := routing{mode: *routingMode, topics: strings.Split(*topics, ",")}
+	if *crash > 0 && (*topology == MESH || *compress || *intervalTreeClocks) {
+		fmt.Println("Crashes need the server of the star topology and cannot be combined with compression or interval tree clocks")
+		os.Exit(1)
+	}
+	if !slices.Contains(server.RecoveryModes, *recovery) || *checkpointInterval <= 0 {
+		fmt.Printf("The recovery must be one of %s and the checkpoint interval positive\n", strings.Join(server.RecoveryModes, ", "))
+		os.Exit(1)
+	}
+	if *maxJump < 0 || *quarantine < 0 || *faulty < 0 {
+		fmt.Println("The maximum jump, the quarantine and the number of faulty clients cannot be negative")
+		os.Exit(1)
+	}
+	if *faulty > 0 && (*topology == MESH || *compress) {
+		fmt.Println("Faulty clients need the server of the star topology and cannot be combined with compression, which would carry a corrupted entry into every later clock of the link")
+		os.Exit(1)
+	}
+	validation := validation{limits: &client.Validation{MaxJump: *maxJump, Quarantine: *quarantine}, faulty: *faulty}
+	if *servers < 1 {
+		fmt.Println("There must be at least one server")
+		os.Exit(1)
+	}
+	if *servers > 1 && (*topology == MESH || *churn > 0 || *crash > 0 || *compress || *matrix || *intervalTreeClocks) {
+		fmt.Println("A federation of servers cannot be combined with the mesh topology, churn, crashes, compression, matrix clocks or interval tree clocks")
+		os.Exit(1)
+	}
+	if !slices.Contains(clock.Backends, *clockBackend) {
+		fmt.Printf("The clock backend must be one of %s\n", strings.Join(clock.Backends, ", "))
+		os.Exit(1)
+	}
+	if *recordFile != "" && *replayFile != "" {
+		fmt.Println("A run cannot record a schedule and replay one at once")
+		os.Exit(1)
+	}
+	if (*recordFile != "" || *replayFile != "") && (*topology == MESH || *servers > 1 || *churn > 0 || *crash > 0 || *faulty > 0 || *compress) {
+		fmt.Println("Schedules need the star topology with a single server, and cannot be combined with churn, crashes, faulty clients or compression")
+		os.Exit(1)
+	}
+	if *fanout < 1 || *rounds < 1 {
+		fmt.Println("The fanout and the number of rounds must be positive")
+		os.Exit(1)
+	}
+	if NumNodes < 2 || (*servers > 1 && NumNodes > server.RegionSize) {
+		fmt.Printf("There must be at least two clients, and at most %d in every region of a federation\n", server.RegionSize)
+		os.Exit(1)
+	}
+	if *sendInterval <= 0 || *duration < 0 || *messages < 0 {
+		fmt.Println("The send interval must be positive, and the duration and the number of messages cannot be negative")
+		os.Exit(1)
+	}
+	if *dropRate < 0 || *dropRate > 1 {
+		fmt.Println("The drop rate must be between 0 and 1")
+		os.Exit(1)
+	}
+	if *bloomSize < 0 || *bloomHashes < 1 {
+		fmt.Println("The size of the Bloom clocks cannot be negative and they need at least one hash")
+		os.Exit(1)
+	}
+	shared := causalMemory{reads: *reads, eager: *eager}
+	if *memoryKeys != "" {
+		shared.keys = strings.Split(*memoryKeys, ",")
+	}
+	if len(shared.keys) > 0 && (*topology == MESH || *servers > 1 || *routingMode != client.BROADCAST || *churn > 0 || *crash > 0 || *faulty > 0 || *recordFile != "" || *replayFile != "") {
+		fmt.Println("Causal memory needs the star topology with a single server and broadcast routing, and cannot be combined with churn, crashes, faulty clients or schedules")
+		os.Exit(1)
+	}
+	if *reads < 0 {
+		fmt.Println("The number of reads cannot be negative")
+		os.Exit(1)
+	}
+	if len(shared.keys) > 0 {
+		shared.history = &memory.History{}
+	}
+	if *historyFile != "" {
+		if shared.history == nil {
+			fmt.Println("The history of the causal memory needs -memory")
+			os.Exit(1)
+		}
+		file, err := os.Create(*historyFile)
+		if err != nil {
+			fmt.Println("Error occurred while creating the history: ", err)
+			os.Exit(1)
+		}
+		shared.history.Output = file
+		defer shared.history.Close()
+	}
+	bounds := &bounds{interval: *sendInterval, duration: *duration, messages: *messages, stop: make(chan struct{}), drained: make(chan struct{})}
 
-	clientChannels := make([]chan client.Message, NumNodes)  // creating a slice of channels for server to send messages to the clients
-	serverChannels := make([]chan client.Message, NumNodes)  // creating a slice of channels for clients to send messages to the server
+	var steps *schedule.Schedule
+	if *recordFile != "" {
+		file, err := os.Create(*recordFile)
+		if err != nil {
+			fmt.Println("Error occurred while creating the schedule: ", err)
+			os.Exit(1)
+		}
+		steps = &schedule.Schedule{Output: file}
+		defer steps.Close()
+	}
+	if *replayFile != "" {
+		file, err := os.Open(*replayFile)
+		if err != nil {
+			fmt.Println("Error occurred while opening the schedule: ", err)
+			os.Exit(1)
+		}
+		steps, err = schedule.Read(file)
+		file.Close()
+		if err != nil {
+			fmt.Println("Error occurred while reading the schedule: ", err)
+			os.Exit(1)
+		}
+	}
 
-	for i := range clientChannels {
-		clientChannels[i] = make(chan client.Message)
-		serverChannels[i] = make(chan client.Message)
+	var recorder *trace.Recorder
+	// the steps of a schedule refer to the messages by their ids in the trace
+	// and a bounded run ends with a summary of the trace, which the Bloom clocks are rebuilt from too
+	if *dotFile != "" || *svgFile != "" || *shivizFile != "" || *logFile != "" || *summary || steps != nil || bounds.bounded() || *bloomSize > 0 {
+		recorder = &trace.Recorder{}
 	}
+	if *logFile != "" {
+		file, err := os.Create(*logFile)
+		if err != nil {
+			fmt.Println("Error occurred while creating the event log: ", err)
+			os.Exit(1)
+		}
+		recorder.Output = file
+		defer recorder.Close()
+	}
+
+	networkConfig := server.DefaultNetworkConfig()
+	if *networkFile != "" {
+		var err error
+		networkConfig, err = server.LoadNetworkConfig(*networkFile)
+		if err != nil {
+			fmt.Println("Error occurred while reading the network configuration: ", err)
+			os.Exit(1)
+		}
+	}
+	if *seed != 0 {
+		networkConfig.Seed = *seed
+	}
+	if isSet("drop-rate") {
+		networkConfig.Default.DropRate = *dropRate
+	}
+	network := server.NewSimulatedNetwork(networkConfig)
+	fmt.Printf("[NETWORK] Random number generator seeded with %d\n", network.Config.Seed)
+
+	var peers []*gossip.Peer
+	var started []*server.Server
+	var clients *participants
+	if *topology == MESH {
+		peers = gossip.NewMesh(NumNodes, *fanout, *rounds, *sendInterval, network, recorder, network.Config.Seed)
+		for _, peer := range peers {
+			startPeer(peer, bounds)
+		}
+	} else if *servers > 1 {
+		started, clients = startFederation(*servers, *clockBackend, network, recorder, routing, queues{capacity: *queueCapacity, policy: *overflow, report: *queueReport}, validation, bounds)
+	} else {
+		started, clients = startStar(*clockBackend, network, recorder, steps, routing, queues{capacity: *queueCapacity, policy: *overflow, report: *queueReport}, *compress, *matrix, *intervalTreeClocks, *churn, crashes{interval: *crash, random: *crashRandom, downtime: *downtime, recovery: *recovery, checkpointFile: *checkpointFile, checkpointInterval: *checkpointInterval}, validation, shared, bounds)
+	}
+
+	bounded := waitForEnd(steps, bounds)
+	if bounded && *topology == MESH {
+		settleMesh(peers)
+	} else if bounded {
+		settle(started, clients)
+		close(bounds.drained)
+	}
+
+	events := recorder.Events()
+	exportTrace(events, *dotFile, trace.WriteDOT)
+	exportTrace(events, *svgFile, trace.WriteSVG)
+	exportTrace(events, *shivizFile, trace.WriteShiViz)
+	if *summary || bounded {
+		for _, line := range trace.Summarize(events).Lines() {
+			fmt.Println("[SUMMARY]", line)
+		}
+		for _, peer := range peers {
+			fmt.Printf("[SUMMARY] client %d detected %d causality violations\n", peer.Id, peer.ViolationCount())
+		}
+	}
+	if *bloomSize > 0 {
+		for _, line := range bloom.Compare(events, *bloomSize, *bloomHashes).Lines() {
+			fmt.Println("[BLOOM]", line)
+		}
+	}
+	if shared.history != nil {
+		reportMemory(clients.clients, shared.history)
+	}
+}
 
-	server := server.Server{make([]int, NumNodes + 1), clientChannels, serverChannels, sync.Mutex{}}
+// waits until the user presses enter, until every step has been taken when a schedule is replayed, or until every
+// client has stopped sending in a bounded run. Returns true if the run ended because it reached its bounds.
+// A bounded run does not read the standard input, so it can run unattended with nothing to read
+func waitForEnd(steps *schedule.Schedule, bounds *bounds) bool {
+	var input chan struct{}
+	if !bounds.bounded() {
+		input = make(chan struct{})
+		go func() {
+			var line string
+			fmt.Scanln(&line)
+			close(input)
+		}()
+	}
 
-	go server.ReceiveMessage()
-	for i := range clientChannels {
-		client := client.Client{int(i), serverChannels[i], clientChannels[i], make([]int, NumNodes + 1)} // every client starts off with a logical clock of 0
-		go client.SendMessage()
-		go client.ReceiveMessage()
+	select {
+	case <-input:
+	case <-steps.Finished():
+		fmt.Println("[REPLAY] Every step of the schedule has been replayed")
+	case <-bounds.end():
+		fmt.Println("[RUN] Every client has stopped sending messages")
+		return true
 	}
+	return false
+}
 
-	var input string
-	fmt.Scanln(&input)
+// Checking if the run ends on its own after a duration or a number of messages
+func (b *bounds) bounded() bool {
+	return b.duration > 0 || b.messages > 0
+}
+
+// starts counting down the duration of a bounded run and returns a channel closed once every client has stopped
+// sending, nil if the run is not bounded
+func (b *bounds) end() <-chan struct{} {
+	if !b.bounded() {
+		return nil
+	}
+
+	if b.duration > 0 {
+		time.AfterFunc(b.duration, func() {
+			close(b.stop)
+		})
+	}
+	ended := make(chan struct{})
+	go func() {
+		b.senders.Wait()
+		close(ended)
+	}()
+	return ended
+}
+
+// waits until the servers have had every message they forwarded acknowledged and the clients have delivered every
+// message they held back, so every message of a bounded run has been delivered or given up on by the time it is
+// summarized. Only the clients still in the system are waited for. Gives up after DrainTimeout
+func settle(servers []*server.Server, p *participants) {
+	deadline := time.Now().Add(DrainTimeout)
+	idle := 0 // consecutive checks without a message in flight, since the last message a server received may not be forwarded yet
+	for {
+		unacknowledged, heldBack := 0, 0
+		for _, s := range servers {
+			unacknowledged += s.Unacknowledged()
+		}
+		p.lock.Lock()
+		clients := slices.Clone(p.clients)
+		p.lock.Unlock()
+		for _, c := range clients {
+			c.Lock.Lock()
+			heldBack += len(c.HoldBack)
+			if c.Memory != nil {
+				heldBack += len(c.Memory.Pending) // writes waiting for the writes they depend on
+			}
+			c.Lock.Unlock()
+		}
+		if unacknowledged == 0 && heldBack == 0 {
+			idle += 1
+		} else {
+			idle = 0
+		}
+		if idle == 2 {
+			fmt.Println("[RUN] Every message has been acknowledged and delivered")
+			return
+		}
+		if time.Now().After(deadline) {
+			fmt.Printf("[RUN] %d messages still unacknowledged and %d held back after %v\n", unacknowledged, heldBack, DrainTimeout)
+			return
+		}
+		time.Sleep(server.RetransmitInterval)
+	}
+}
+
+// waits until no peer of the mesh has a rumor left to push, so every rumor of a bounded run has spread as far as it
+// will by the time it is summarized. Gives up after DrainTimeout
+func settleMesh(peers []*gossip.Peer) {
+	deadline := time.Now().Add(DrainTimeout)
+	idle := 0 // consecutive checks without a rumor to push, since a push still on its way may bring a peer a new one
+	for {
+		spreading := 0
+		for _, peer := range peers {
+			spreading += peer.Spreading()
+		}
+		if spreading == 0 {
+			idle += 1
+		} else {
+			idle = 0
+		}
+		if idle == 2 {
+			fmt.Println("[RUN] Every rumor has stopped spreading")
+			return
+		}
+		if time.Now().After(deadline) {
+			fmt.Printf("[RUN] %d rumors still spreading after %v\n", spreading, DrainTimeout)
+			return
+		}
+		time.Sleep(gossip.RoundInterval)
+	}
+}
+
+// starts the server and the clients that send every message through it
+func startStar(backend string, network server.NetworkModel, recorder *trace.Recorder, steps *schedule.Schedule, routing routing, queues queues, compress bool, matrix bool, intervalTreeClocks bool, churn time.Duration, crashes crashes, validation validation, shared causalMemory, bounds *bounds) (started []*server.Server, clients *participants) {
+	server := newServer(0, trace.SERVER, backend, network, recorder, queues, compress, crashes, validation)
+	server.Schedule = steps
+
+	if matrix {
+		server.Knowledge = make(client.MatrixClock)
+	}
+	if intervalTreeClocks {
+		seed := itc.Seed() // the server starts off owning the whole interval and forks it for the clients
+		server.Stamp = &seed
+	}
+
+	clients = &participants{}
+	for range NumNodes {
+		client := server.Join()
+		if len(shared.keys) > 0 {
+			client.Memory = memory.NewReplica(client.Id, shared.eager, shared.history)
+			client.Keys = shared.keys
+			client.Reads = shared.reads
+		}
+		startClient(server, client, routing, validation, bounds)
+		clients.clients = append(clients.clients, client)
+	}
+
+	go server.RetransmitMessages()
+
+	if churn > 0 {
+		bounds.senders.Add(1)
+		go func() {
+			simulateChurn(server, clients, routing, validation, churn, intervalTreeClocks, bounds)
+			bounds.senders.Done()
+		}()
+	}
+	if intervalTreeClocks {
+		go reportClockSizes(server, clients)
+	}
+	if queues.report > 0 {
+		go reportQueues(server, queues.report)
+	}
+	if crashes.interval > 0 {
+		go server.WriteCheckpoints(crashes.checkpointInterval)
+		go simulateCrashes(server, crashes)
+	}
+	return append(started, server), clients
+}
+
+// starts the servers of a federation linked in a chain, each with its own clients
+func startFederation(count int, backend string, network server.NetworkModel, recorder *trace.Recorder, routing routing, queues queues, validation validation, bounds *bounds) ([]*server.Server, *participants) {
+	servers := make([]*server.Server, count)
+	clients := make([][]*client.Client, count)
+	everyone := make([]*client.Client, 0)
+	for region := range count {
+		servers[region] = newServer(region, trace.Server(region), backend, network, recorder, queues, false, crashes{}, validation)
+		for range NumNodes {
+			clients[region] = append(clients[region], servers[region].Join())
+		}
+		everyone = append(everyone, clients[region]...)
+	}
+
+	// the servers learn which nodes the other regions have before any clock carries their entries
+	server.Federate(servers)
+	for region := range count {
+		for _, client := range clients[region] {
+			startClient(servers[region], client, routing, validation, bounds)
+		}
+		go servers[region].RetransmitMessages()
+		if queues.report > 0 {
+			go reportQueues(servers[region], queues.report)
+		}
+	}
+	return servers, &participants{clients: everyone}
+}
+
+// creates the server of a region, whose clients get ids from region * RegionSize on. Without a federation there is only region 0
+func newServer(region int, node string, backend string, network server.NetworkModel, recorder *trace.Recorder, queues queues, compress bool, crashes crashes, validation validation) *server.Server {
+	return &server.Server{
+		Id: server.ServerId - region,
+		Node: node,
+		Clock: clock.NewVector(backend), // server starts off with a logical clock of 0
+		SendChannels: make(map[int]chan client.Message), // channels for server to send messages to the clients
+		ReceiveChannels: make(map[int]chan client.Message), // channels for clients to send messages to the server
+		Departed: make(map[int]chan struct{}),
+		History: make(map[int][]client.Message),
+		Retired: make(map[int]bool),
+		NextId: region * server.RegionSize,
+		Network: network,
+		NextSeq: make(map[int]int),
+		Pending: make(map[int]map[int]*server.PendingMessage),
+		Trace: recorder,
+		Compress: compress,
+		HistoryBase: make(map[int]int),
+		Encoders: make(map[int]*client.DiffEncoder),
+		Topics: make(map[string]map[int]bool),
+		Queues: make(map[int]*server.OutboundQueue),
+		QueueCapacity: queues.capacity,
+		OverflowPolicy: queues.policy,
+		Recovery: crashes.recovery,
+		CheckpointFile: crashes.checkpointFile,
+		Validation: validation.limits,
+		Quarantined: make(map[int]time.Time),
+		Relayed: make(chan client.Message),
+		Remote: make(map[int]bool),
+	}
+}
+
+// crashes the server at every interval, or at random points once per interval on average, and restarts it after the downtime
+func simulateCrashes(server *server.Server, crashes crashes) {
+	for {
+		interval := crashes.interval
+		if crashes.random {
+			interval = time.Duration(rand.ExpFloat64() * float64(crashes.interval))
+		}
+		time.Sleep(interval)
+
+		server.Crash()
+		time.Sleep(crashes.downtime)
+		server.Restart()
+	}
+}
+
+// subscribes the client to a topic and starts it
+func startClient(server *server.Server, client *client.Client, routing routing, validation validation, bounds *bounds) {
+	client.SendInterval = bounds.interval
+	client.Messages = bounds.messages
+	client.Stop = bounds.stop
+	client.Validation = validation.limits
+	client.Faulty = client.Id < validation.faulty
+	client.Routing = routing.mode
+	client.Topics = routing.topics
+	server.Subscribe(client.Id, routing.topics[client.Id % len(routing.topics)])
+
+	bounds.senders.Add(1)
+	go func() {
+		client.SendMessage()
+		bounds.senders.Done()
+		if client.Memory != nil {
+			// the writes that arrive after the client's last message are still read while the run settles
+			client.ReadMemory(bounds.drained)
+		}
+	}()
+	go client.ReceiveMessage()
+	go client.RequestRedelivery()
+}
+
+// lets the peer start as many rumors as a client sends messages, and starts it
+func startPeer(peer *gossip.Peer, bounds *bounds) {
+	peer.Rumors = bounds.messages
+	peer.Stop = bounds.stop
+
+	bounds.senders.Add(1)
+	go func() {
+		peer.StartRumors()
+		bounds.senders.Done()
+	}()
+	go peer.PushRumors()
+	go peer.ReceiveMessages()
+}
+
+// writes the recorded events to a file in one of the export formats, if a file was given
+func exportTrace(events []trace.Event, path string, write func(io.Writer, []trace.Event) error) {
+	if path == "" {
+		return
+	}
+
+	if err := trace.WriteFile(path, write, events); err != nil {
+		fmt.Printf("Error occurred while writing %s: %s\n", path, err)
+		return
+	}
+	fmt.Printf("[TRACE] %d events written to %s\n", len(events), path)
+}
+
+// randomly makes a new client join or an existing client leave every interval, until the run is over or, in a run
+// bounded by a number of messages, after as many joins and leaves as every client sends messages.
+// With interval tree clocks the new client is forked off a random client rather than off the server.
+func simulateChurn(server *server.Server, p *participants, routing routing, validation validation, interval time.Duration, fork bool, bounds *bounds) {
+	for changes := 1; ; changes++ {
+		select {
+		case <-bounds.stop:
+			return
+		case <-time.After(interval):
+		}
+
+		p.lock.Lock()
+		if len(p.clients) > 1 && rand.Intn(2) == 0 {
+			i := rand.Intn(len(p.clients))
+			p.clients[i].Leave()
+			p.clients = slices.Delete(p.clients, i, i + 1)
+		} else {
+			var client *client.Client
+			if fork && len(p.clients) > 0 {
+				client = server.JoinFork(p.clients[rand.Intn(len(p.clients))])
+			} else {
+				client = server.Join()
+			}
+			startClient(server, client, routing, validation, bounds)
+			p.clients = append(p.clients, client)
+		}
+		p.lock.Unlock()
+		if changes == bounds.messages {
+			return
+		}
+	}
+}
+
+// periodically compares the encoded sizes of the interval tree clocks and of the vector clocks of every participant
+func reportClockSizes(server *server.Server, p *participants) {
+	for {
+		time.Sleep(SizeReportInterval)
+
+		vectorTotal, stampTotal := server.ClockSizes()
+		vectorMax, stampMax := vectorTotal, stampTotal
+		p.lock.Lock()
+		for _, client := range p.clients {
+			vectorSize, stampSize := client.ClockSizes()
+			vectorTotal, stampTotal = vectorTotal + vectorSize, stampTotal + stampSize
+			vectorMax, stampMax = max(vectorMax, vectorSize), max(stampMax, stampSize)
+		}
+		count := len(p.clients) + 1
+		p.lock.Unlock()
+
+		fmt.Printf("[CLOCK SIZES] %d participants: interval tree clocks %.1f bytes on average (max %d), vector clocks %.1f bytes on average (max %d), %d ids retired from the vector clocks\n",
+			count, float64(stampTotal) / float64(count), stampMax, float64(vectorTotal) / float64(count), vectorMax, server.RetiredCount())
+	}
+}
+
+// periodically reports how many messages wait in the server's queues and how many were dropped because a queue was full
+func reportQueues(server *server.Server, interval time.Duration) {
+	for {
+		time.Sleep(interval)
+
+		queues := server.QueueMetrics()
+		if len(queues) == 0 {
+			continue
+		}
+		depth, overflowed, deepest := 0, 0, queues[0]
+		for _, metrics := range queues {
+			depth, overflowed = depth + metrics.Depth, overflowed + metrics.Overflowed
+			if metrics.MaxDepth > deepest.MaxDepth {
+				deepest = metrics
+			}
+		}
+		fmt.Printf("[QUEUES] %d queues: %d messages waiting, %d dropped on overflow, deepest client %d with %d waiting (at most %d)\n",
+			len(queues), depth, overflowed, deepest.ClientId, deepest.Depth, deepest.MaxDepth)
+	}
+}
+
+// checks that the reads and writes of the causal memory are causally consistent and reports what every replica applied
+func reportMemory(clients []*client.Client, history *memory.History) {
+	operations := history.Operations()
+	violations := memory.Check(operations)
+	for _, violation := range violations {
+		fmt.Println("[MEMORY]", violation)
+	}
+	fmt.Printf("[MEMORY] %d operations checked, %d causal consistency violations found\n", len(operations), len(violations))
+
+	for _, client := range clients {
+		client.Lock.Lock()
+		applied := 0
+		for _, count := range client.Memory.Applied {
+			applied += count
+		}
+		fmt.Printf("[MEMORY] client %d applied %d writes, %d waiting\n", client.Id, applied, len(client.Memory.Pending))
+		client.Lock.Unlock()
+	}
 }
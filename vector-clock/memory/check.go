@@ -0,0 +1,173 @@
+package memory
+
+import "fmt"
+
+// Patterns of a history that is not causally consistent, from "On Verifying Causal Consistency" (Bouajjani et al.).
+// The writes of a history all have their own id, so every read tells which write it returned the value of
+const (
+	THIN_AIR_READ = "thin-air read" // a read returns a value no write wrote to its key
+	CYCLIC_CAUSALITY = "cyclic causality" // the causal order, the order of every replica's operations and of every write before the reads that return it, has a cycle
+	WRITE_CO_INIT_READ = "initial value read" // a read returns the initial value of a key although a write to the key is causally before it
+	WRITE_CO_READ = "overwritten value read" // a read returns the value of a write that another write to the key, causally between them, overwrote
+)
+
+// Violation is a read that shows the history is not causally consistent
+type Violation struct {
+	Pattern string
+	Operation Operation
+	Detail string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s at replica %d %s: %s", v.Pattern, v.Operation.Replica, Describe(v.Operation), v.Detail)
+}
+
+// short description of an operation, like read x=1/4 or write y=2/1
+func Describe(operation Operation) string {
+	if operation.Write == "" {
+		return fmt.Sprintf("%s %s=initial", operation.Type, operation.Key)
+	}
+	return fmt.Sprintf("%s %s=%s (write %s)", operation.Type, operation.Key, operation.Value, operation.Write)
+}
+
+// Checks that the operations of every replica, in the order they were recorded, are causally consistent: every
+// replica sees the writes in an order that respects the causal order, though not necessarily the same order as the
+// other replicas. The history has to contain every write the reads return
+func Check(operations []Operation) []Violation {
+	violations := make([]Violation, 0)
+	writes := make(map[string]int) // index of every write by its id
+	byKey := make(map[string][]int) // indexes of the writes to each key
+	for i, operation := range operations {
+		if operation.Type == WRITE {
+			writes[operation.Write] = i
+			byKey[operation.Key] = append(byKey[operation.Key], i)
+		}
+	}
+
+	// the causal order is made of the order of the operations of every replica and of every write before the reads
+	// that return its value
+	predecessors := make([][]int, len(operations))
+	readsFrom := make([]int, len(operations)) // write a read returns the value of, -1 for the initial value
+	last := make(map[int]int) // previous operation of each replica
+	for i, operation := range operations {
+		if previous, ok := last[operation.Replica]; ok {
+			predecessors[i] = append(predecessors[i], previous)
+		}
+		last[operation.Replica] = i
+		readsFrom[i] = -1
+		if operation.Type != READ || operation.Write == "" {
+			continue
+		}
+
+		write, ok := writes[operation.Write]
+		if !ok || operations[write].Key != operation.Key || operations[write].Value != operation.Value {
+			violations = append(violations, Violation{THIN_AIR_READ, operation, "no write in the history wrote this value to the key"})
+			continue
+		}
+		readsFrom[i] = write
+		predecessors[i] = append(predecessors[i], write)
+	}
+
+	order := topologicalOrder(predecessors)
+	if len(order) < len(operations) {
+		first := firstMissing(order, len(operations))
+		return append(violations, Violation{CYCLIC_CAUSALITY, operations[first], fmt.Sprintf("%d operations are on or after a cycle of the causal order", len(operations) - len(order))})
+	}
+	before := causalPast(order, predecessors)
+
+	for i, operation := range operations {
+		if operation.Type != READ || (operation.Write != "" && readsFrom[i] == -1) {
+			continue
+		}
+		for _, write := range byKey[operation.Key] {
+			if write == readsFrom[i] || !before[i].has(write) {
+				continue
+			}
+			if readsFrom[i] == -1 {
+				violations = append(violations, Violation{WRITE_CO_INIT_READ, operation, fmt.Sprintf("%s is causally before the read", Describe(operations[write]))})
+				break
+			}
+			if before[write].has(readsFrom[i]) {
+				violations = append(violations, Violation{WRITE_CO_READ, operation, fmt.Sprintf("%s overwrote it and is causally before the read", Describe(operations[write]))})
+				break
+			}
+		}
+	}
+	return violations
+}
+
+// orders the operations so every operation comes after its predecessors. Operations on or after a cycle are left out
+func topologicalOrder(predecessors [][]int) []int {
+	successors := make([][]int, len(predecessors))
+	waiting := make([]int, len(predecessors)) // predecessors of each operation not ordered yet
+	for i, previous := range predecessors {
+		waiting[i] = len(previous)
+		for _, p := range previous {
+			successors[p] = append(successors[p], i)
+		}
+	}
+
+	order := make([]int, 0, len(predecessors))
+	for i := range predecessors {
+		if waiting[i] == 0 {
+			order = append(order, i)
+		}
+	}
+	for next := 0; next < len(order); next++ {
+		for _, s := range successors[order[next]] {
+			waiting[s] -= 1
+			if waiting[s] == 0 {
+				order = append(order, s)
+			}
+		}
+	}
+	return order
+}
+
+// first operation left out of an order
+func firstMissing(order []int, count int) int {
+	ordered := make([]bool, count)
+	for _, i := range order {
+		ordered[i] = true
+	}
+	for i := range ordered {
+		if !ordered[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// operations causally before every operation, following an order in which every operation comes after its predecessors
+func causalPast(order []int, predecessors [][]int) []bitset {
+	before := make([]bitset, len(predecessors))
+	for _, i := range order {
+		before[i] = newBitset(len(predecessors))
+		for _, p := range predecessors[i] {
+			before[i].union(before[p])
+			before[i].set(p)
+		}
+	}
+	return before
+}
+
+// set of operations by index
+type bitset []uint64
+
+func newBitset(size int) bitset {
+	return make(bitset, (size + 63) / 64)
+}
+
+func (b bitset) set(i int) {
+	b[i / 64] |= 1 << (i % 64)
+}
+
+func (b bitset) has(i int) bool {
+	return b[i / 64] & (1 << (i % 64)) != 0
+}
+
+func (b bitset) union(other bitset) {
+	for i := range b {
+		b[i] |= other[i]
+	}
+}
@@ -0,0 +1,136 @@
+package memory
+
+import "testing"
+
+func write(replica int, key string, value string, id string) Operation {
+	return Operation{Replica: replica, Type: WRITE, Key: key, Value: value, Write: id}
+}
+
+func read(replica int, key string, value string, id string) Operation {
+	return Operation{Replica: replica, Type: READ, Key: key, Value: value, Write: id}
+}
+
+// hand-built histories, one for each pattern of a history that is not causally consistent and a few that are
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		operations []Operation
+		patterns []string // patterns of the violations found, in order
+	}{
+		{
+			name: "reads of concurrent writes in different orders",
+			operations: []Operation{
+				write(0, "x", "1", "0/1"),
+				write(1, "x", "2", "1/1"),
+				read(2, "x", "1", "0/1"),
+				read(2, "x", "2", "1/1"),
+				read(3, "x", "2", "1/1"),
+				read(3, "x", "1", "0/1"),
+			},
+		},
+		{
+			name: "initial value read before any write",
+			operations: []Operation{
+				read(1, "x", "", ""),
+				write(0, "x", "1", "0/1"),
+				read(1, "x", "1", "0/1"),
+			},
+		},
+		{
+			name: "value no write wrote",
+			operations: []Operation{
+				write(0, "x", "1", "0/1"),
+				read(1, "x", "7", "2/1"),
+			},
+			patterns: []string{THIN_AIR_READ},
+		},
+		{
+			name: "value of a write to another key",
+			operations: []Operation{
+				write(0, "x", "1", "0/1"),
+				read(1, "y", "1", "0/1"),
+			},
+			patterns: []string{THIN_AIR_READ},
+		},
+		{
+			name: "each replica reads the value the other writes afterwards",
+			operations: []Operation{
+				read(0, "x", "1", "1/1"),
+				write(0, "y", "1", "0/1"),
+				read(1, "y", "1", "0/1"),
+				write(1, "x", "1", "1/1"),
+			},
+			patterns: []string{CYCLIC_CAUSALITY},
+		},
+		{
+			name: "initial value read after reading a write that depends on a write to the key",
+			operations: []Operation{
+				write(0, "x", "1", "0/1"),
+				write(0, "y", "2", "0/2"),
+				read(1, "y", "2", "0/2"),
+				read(1, "x", "", ""),
+			},
+			patterns: []string{WRITE_CO_INIT_READ},
+		},
+		{
+			name: "initial value read after the replica's own write",
+			operations: []Operation{
+				write(0, "x", "1", "0/1"),
+				read(0, "x", "", ""),
+			},
+			patterns: []string{WRITE_CO_INIT_READ},
+		},
+		{
+			name: "overwritten value read after reading the write that overwrote it",
+			operations: []Operation{
+				write(0, "x", "1", "0/1"),
+				write(0, "x", "2", "0/2"),
+				read(1, "x", "2", "0/2"),
+				read(1, "x", "1", "0/1"),
+			},
+			patterns: []string{WRITE_CO_READ},
+		},
+		{
+			name: "overwritten value read after a write of the reader that depends on the overwrite",
+			operations: []Operation{
+				write(0, "x", "1", "0/1"),
+				read(1, "x", "1", "0/1"),
+				write(1, "x", "2", "1/1"),
+				read(2, "x", "2", "1/1"),
+				read(2, "x", "1", "0/1"),
+			},
+			patterns: []string{WRITE_CO_READ},
+		},
+	}
+
+	for _, test := range tests {
+		violations := Check(test.operations)
+		if len(violations) != len(test.patterns) {
+			t.Errorf("%s: found %v, want violations %v", test.name, violations, test.patterns)
+			continue
+		}
+		for i, violation := range violations {
+			if violation.Pattern != test.patterns[i] {
+				t.Errorf("%s: violation %d is %v, want a %s", test.name, i, violation, test.patterns[i])
+			}
+		}
+	}
+}
+
+// the violation points at the read that shows it
+func TestViolationOperation(t *testing.T) {
+	stale := read(1, "x", "1", "0/1")
+	violations := Check([]Operation{
+		write(0, "x", "1", "0/1"),
+		write(0, "x", "2", "0/2"),
+		read(1, "x", "2", "0/2"),
+		stale,
+	})
+	if len(violations) != 1 || violations[0].Operation != stale {
+		t.Fatalf("found %v, want a single violation at %v", violations, stale)
+	}
+	want := "overwritten value read at replica 1 read x=1 (write 0/1): write x=2 (write 0/2) overwrote it and is causally before the read"
+	if got := violations[0].String(); got != want {
+		t.Errorf("violation is %q, want %q", got, want)
+	}
+}
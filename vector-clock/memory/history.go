@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Operation types
+const (
+	READ  = "read"
+	WRITE = "write"
+)
+
+// Operation is a read or a write of one replica, as its client saw it
+type Operation struct {
+	Replica int `json:"replica"`
+	Type string `json:"type"` // READ | WRITE
+	Key string `json:"key"`
+	Value string `json:"value,omitempty"`
+	Write string `json:"write,omitempty"` // id of the write, or of the write a read returned the value of. Empty for a read of a key that was never written
+	Time time.Time `json:"time"`
+}
+
+// History keeps the operations of every replica in the order they were recorded, and writes each of them as a JSON
+// line to Output if it is set. A nil History records nothing
+type History struct {
+	Output io.Writer
+	operations []Operation
+	lock sync.Mutex
+}
+
+func (h *History) Record(operation Operation) {
+	if h == nil {
+		return
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	operation.Time = time.Now()
+	h.operations = append(h.operations, operation)
+
+	if h.Output != nil {
+		if err := json.NewEncoder(h.Output).Encode(operation); err != nil {
+			fmt.Printf("Error occurred while writing the history: %s\n", err)
+		}
+	}
+}
+
+// Stops writing operations to Output and closes it. Operations recorded afterwards are only kept in memory.
+func (h *History) Close() error {
+	if h == nil {
+		return nil
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	output := h.Output
+	h.Output = nil
+	if closer, ok := output.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Every operation recorded so far
+func (h *History) Operations() []Operation {
+	if h == nil {
+		return nil
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	operations := make([]Operation, len(h.operations))
+	copy(operations, h.operations)
+	return operations
+}
+
+// Reads the operations written by a History as JSON lines
+func ReadOperations(r io.Reader) ([]Operation, error) {
+	operations := make([]Operation, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64 * 1024), 1024 * 1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var operation Operation
+		if err := json.Unmarshal(scanner.Bytes(), &operation); err != nil {
+			return operations, err
+		}
+		operations = append(operations, operation)
+	}
+	return operations, scanner.Err()
+}
@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Write of a value to a key, tagged with the vector timestamp of the writes its replica had applied when it wrote it
+type Write struct {
+	Id string `json:"id"` // replica and number of the write, like 2/5
+	Replica int `json:"replica"`
+	Key string `json:"key"`
+	Value string `json:"value"`
+	Timestamp map[int]int `json:"timestamp"` // writes of each replica applied at the writer, the write itself included
+}
+
+// Replica of the causal memory kept by every client. A replica applies its own writes at once, and the writes of the
+// other replicas once it has applied every write they depend on, so a read never returns a value without the writes
+// that caused it. Replicas only agree on the order of writes that are causally related, so two replicas may apply
+// concurrent writes to the same key in different orders and keep different values.
+// A replica is not safe for concurrent use, its client only uses it with its lock held.
+type Replica struct {
+	Id int
+	Values map[string]Write // last write applied to each key
+	Applied map[int]int // number of writes of each replica applied
+	Seen map[string]bool // ids of the writes applied
+	Pending []Write // writes of other replicas waiting for the writes they depend on
+	Eager bool // the writes of the other replicas are applied as soon as they arrive, whatever they depend on
+	History *History // records the reads and writes of the replica, nil if they are not recorded
+}
+
+func NewReplica(id int, eager bool, history *History) *Replica {
+	return &Replica{
+		Id: id,
+		Values: make(map[string]Write),
+		Applied: make(map[int]int),
+		Seen: make(map[string]bool),
+		Eager: eager,
+		History: history,
+	}
+}
+
+// Reads the value of a key, the zero Write if the replica has not applied any write to it
+func (r *Replica) Read(key string) Write {
+	write := r.Values[key]
+	r.History.Record(Operation{Replica: r.Id, Type: READ, Key: key, Value: write.Value, Write: write.Id})
+	return write
+}
+
+// Writes a value to a key and returns the write to send to the other replicas
+func (r *Replica) Write(key string, value string) Write {
+	r.Applied[r.Id] += 1
+	write := Write{
+		Id: fmt.Sprintf("%d/%d", r.Id, r.Applied[r.Id]),
+		Replica: r.Id,
+		Key: key,
+		Value: value,
+		Timestamp: maps.Clone(r.Applied),
+	}
+	r.Seen[write.Id] = true
+	r.Values[key] = write
+	r.History.Record(Operation{Replica: r.Id, Type: WRITE, Key: key, Value: value, Write: write.Id})
+	return write
+}
+
+// Checking if a write has already been applied or is already waiting for the writes it depends on
+func (r *Replica) Has(write Write) bool {
+	return r.Seen[write.Id] || slices.ContainsFunc(r.Pending, func(pending Write) bool { return pending.Id == write.Id })
+}
+
+// Receives a write of another replica and applies every write that is ready, returns the writes applied in the order
+// they were applied. A write the replica already has is ignored
+func (r *Replica) Receive(write Write) []Write {
+	if r.Has(write) {
+		return nil
+	}
+	if r.Eager {
+		r.apply(write)
+		return []Write{write}
+	}
+
+	r.Pending = append(r.Pending, write)
+	applied := make([]Write, 0)
+	for{
+		index := slices.IndexFunc(r.Pending, r.isReady)
+		if index == -1 {
+			return applied
+		}
+		ready := r.Pending[index]
+		r.Pending = slices.Delete(r.Pending, index, index + 1)
+		r.apply(ready)
+		applied = append(applied, ready)
+	}
+}
+
+// Checking if every write a write depends on has been applied: it has to be the next write of its replica, and every
+// write of the other replicas its replica had applied must have been applied here too
+func (r *Replica) isReady(write Write) bool {
+	for id, count := range write.Timestamp{
+		if id == write.Replica {
+			if count != r.Applied[id] + 1 {
+				return false
+			}
+		} else if count > r.Applied[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Replica) apply(write Write) {
+	r.Applied[write.Replica] = max(r.Applied[write.Replica], write.Timestamp[write.Replica])
+	r.Seen[write.Id] = true
+	r.Values[write.Key] = write
+}
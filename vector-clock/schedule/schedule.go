@@ -0,0 +1,211 @@
+package schedule
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Kinds of steps
+const (
+	SEND = "send" // a client sends a message to the server
+	REQUEST = "request" // a client asks the server to redeliver the messages it misses
+	RECEIVE = "receive" // a node handles a message it received, duplicates aside
+	FORWARD = "forward" // the server forwards a message to a client
+	REDELIVER = "redeliver" // the server forwards a message from its history to a client that misses it
+	TRANSMIT = "transmit" // the network decides what happens to a transmission to a client. Not a step of a node, only its outcome is replayed
+)
+
+// Step is something a node does that changes its clock, or a decision of the network.
+// Peer and MessageId tell apart the steps of a node that different goroutines take.
+type Step struct {
+	Node string `json:"node"`
+	Kind string `json:"kind"` // SEND | REQUEST | RECEIVE | FORWARD | REDELIVER | TRANSMIT
+	Peer string `json:"peer,omitempty"` // recipient of a forward or a transmission
+	MessageId string `json:"message_id,omitempty"` // message received, or message a forward or a redelivery passes on
+	To []int `json:"to,omitempty"` // clients a sent message was addressed to
+	Topic string `json:"topic,omitempty"` // topic a sent message was addressed to
+	Seq int `json:"seq,omitempty"` // sequence number of a transmission on its link
+	Attempt int `json:"attempt,omitempty"` // 1 for the first transmission of a message, more for its retransmissions
+	Dropped bool `json:"dropped,omitempty"`
+	Delays []time.Duration `json:"delays,omitempty"` // latency of every copy the network delivered
+}
+
+// Schedule records the steps of a run as JSON lines to Output, or replays the steps of a recorded run so every node
+// takes its steps in the same order and receives the same messages, which gives every event the same clock.
+// Only the order of the steps of each node is replayed, nodes still run side by side.
+// A nil Schedule neither records nor replays, so the run is left to the scheduler and the network.
+type Schedule struct {
+	Output io.Writer
+	steps map[string][]Step // steps of every node, in the order it took them, when replaying
+	next map[string]int // next step of every node
+	remaining int // steps not taken yet
+	decisions map[string]Step // decisions of the network by link, sequence number and attempt
+	advanced chan struct{} // closed whenever a node takes a step
+	finished chan struct{} // closed once every step has been taken
+	lock sync.Mutex
+}
+
+// Reads a schedule recorded as JSON lines to replay it
+func Read(r io.Reader) (*Schedule, error) {
+	s := &Schedule{
+		steps: make(map[string][]Step),
+		next: make(map[string]int),
+		decisions: make(map[string]Step),
+		advanced: make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64 * 1024), 1024 * 1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var step Step
+		if err := json.Unmarshal(scanner.Bytes(), &step); err != nil {
+			return nil, err
+		}
+		if step.Kind == TRANSMIT {
+			s.decisions[decisionKey(step)] = step
+		} else {
+			s.steps[step.Node] = append(s.steps[step.Node], step)
+			s.remaining += 1
+		}
+	}
+	if s.remaining == 0 {
+		close(s.finished)
+	}
+	return s, scanner.Err()
+}
+
+func decisionKey(step Step) string {
+	return fmt.Sprintf("%s/%d/%d", step.Peer, step.Seq, step.Attempt)
+}
+
+// Checking if the schedule is being replayed rather than recorded
+func (s *Schedule) Replaying() bool {
+	return s != nil && s.steps != nil
+}
+
+// Waits until the given step is the next step of its node when replaying, and returns it as it was recorded.
+// Must not be called with a lock the other steps need held
+func (s *Schedule) Await(step Step) Step {
+	if !s.Replaying() {
+		return step
+	}
+
+	for{
+		s.lock.Lock()
+		if recorded, ok := s.peek(step); ok {
+			s.lock.Unlock()
+			return recorded
+		}
+		advanced := s.advanced
+		s.lock.Unlock()
+		<-advanced
+	}
+}
+
+// Checking if the given step is the next step of its node when replaying
+func (s *Schedule) IsNext(step Step) bool {
+	if !s.Replaying() {
+		return true
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.peek(step)
+	return ok
+}
+
+// Must be called with the lock held
+func (s *Schedule) peek(step Step) (Step, bool) {
+	steps := s.steps[step.Node]
+	i := s.next[step.Node]
+	if i == len(steps) {
+		return Step{}, false
+	}
+	next := steps[i]
+	return next, next.Kind == step.Kind && next.Peer == step.Peer && next.MessageId == step.MessageId
+}
+
+// Channel closed the next time a node takes a step when replaying, nil otherwise
+func (s *Schedule) Advanced() <-chan struct{} {
+	if !s.Replaying() {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.advanced
+}
+
+// Channel closed once every step of the schedule has been taken when replaying, nil otherwise
+func (s *Schedule) Finished() <-chan struct{} {
+	if !s.Replaying() {
+		return nil
+	}
+	return s.finished
+}
+
+// Records a step once the node has taken it, or moves on to the next step of the node when replaying.
+// The step of a node must be done with the node's lock held, so its steps are recorded in the order it took them
+func (s *Schedule) Done(step Step) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.Replaying() {
+		if step.Kind == TRANSMIT || s.next[step.Node] == len(s.steps[step.Node]) {
+			return
+		}
+		s.next[step.Node] += 1
+		s.remaining -= 1
+		close(s.advanced)
+		s.advanced = make(chan struct{})
+		if s.remaining == 0 {
+			close(s.finished)
+		}
+		return
+	}
+
+	if s.Output != nil {
+		if err := json.NewEncoder(s.Output).Encode(step); err != nil {
+			fmt.Printf("Error occurred while writing the schedule: %s\n", err)
+		}
+	}
+}
+
+// Decision the network took for a transmission in the recorded run, false if it is not replayed or was not recorded
+func (s *Schedule) Decision(step Step) (Step, bool) {
+	if !s.Replaying() {
+		return Step{}, false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	decision, ok := s.decisions[decisionKey(step)]
+	return decision, ok
+}
+
+// Stops writing steps to Output and closes it
+func (s *Schedule) Close() error {
+	if s == nil {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	output := s.Output
+	s.Output = nil
+	if closer, ok := output.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"vector-clock/client"
+	"vector-clock/trace"
+	"math"
+)
+
+const (
+	RegionSize = 1000 // the ids of the clients of region k in a federation start at k * RegionSize
+)
+
+// link from a server of a federation to a neighbouring server. Links between servers are reliable and FIFO,
+// like the backbone between regional brokers, so they do not go through the network model
+type Link struct {
+	Peer *Server
+	Queue *OutboundQueue // messages waiting to be put on the neighbour's channel
+}
+
+// function to connect the servers of a federation in a chain, so a message from the first region crosses every
+// server to reach the last one. Must be called once the clients of every server have joined, before they start sending
+func Federate(servers []*Server) {
+	for i := 1; i < len(servers); i++ {
+		servers[i - 1].connect(servers[i])
+		servers[i].connect(servers[i - 1])
+	}
+
+	for _, s := range servers{
+		for _, other := range servers{
+			if other == s {
+				continue
+			}
+			other.Lock.Lock()
+			ids := other.clientIds(other.Id)
+			other.Lock.Unlock()
+
+			s.Lock.Lock()
+			s.Remote[other.Id] = true
+			for _, id := range ids{
+				s.Remote[id] = true
+			}
+			s.Lock.Unlock()
+		}
+		go s.handleRelayed()
+	}
+}
+
+// function to add a link to a neighbouring server, with its own queue and sender goroutine
+func (s *Server) connect(peer *Server) {
+	// The queue is unbounded: a server that waited for room to relay a message would stop taking the messages its
+	// neighbours relay, and two neighbours relaying to each other through full queues would wait for each other for good
+	link := &Link{Peer: peer, Queue: NewOutboundQueue(math.MaxInt, BLOCK)}
+	s.Links = append(s.Links, link)
+	fmt.Println(fmt.Sprintf("[SERVER-VC%v] Linked to %s", s.Clock.Load(), peer.Node))
+
+	go func() {
+		for{
+			message, ok := link.Queue.Pop()
+			if !ok {
+				return
+			}
+			peer.Relayed <- message
+		}
+	}()
+}
+
+// function to handle the messages relayed by the neighbouring servers
+func (s *Server) handleRelayed() {
+	for{
+		msg := <- s.Relayed
+
+		end := s.beginStep()
+		if !msg.IsDirected() {
+			// a client that misses a broadcast from another region requests it from its own server
+			s.Lock.Lock()
+			s.History[msg.ClientId] = append(s.History[msg.ClientId], msg)
+			s.Lock.Unlock()
+		}
+		clockBefore, currentClock := s.Clock.Update(func(clock client.VectorClock) {
+			client.VectorMAX(clock, msg.Clock) // updating the logical clock by finding the maximum between the two clock values
+			clock[s.Id] += 1
+		})
+		fmt.Println(fmt.Sprintf("[SERVER-VC%v] Message of client %d relayed by %s for %s: '%s'", currentClock, msg.ClientId, msg.RelayedBy, msg.Recipients(), msg.Message))
+		s.Trace.Record(trace.Event{Node: s.Node, Type: trace.RECEIVE, Peer: msg.RelayedBy, MessageId: msg.MessageId, ClockBefore: clockBefore, Clock: currentClock, Description: msg.Message})
+		end()
+
+		s.sendMessage(msg)
+	}
+}
+
+// function to relay a message to every neighbouring server except the one it came from. The timestamp and the
+// sender's clock travel unchanged, so the clients of every region deliver the message in causal order and can
+// tell if it arrives after a message that depends on it, however many servers it crossed
+func (s *Server) relay(message client.Message) {
+	for _, link := range s.Links{
+		if link.Peer.Node == message.RelayedBy {
+			continue
+		}
+
+		end := s.beginStep()
+		clockBefore, currentClock := s.tick()
+		relayed := message
+		relayed.Clock = currentClock
+		relayed.RelayedBy = s.Node
+		relayed.MessageId = s.Trace.NewMessageId(s.Node)
+		s.Trace.Record(trace.Event{Node: s.Node, Type: trace.SEND, Peer: link.Peer.Node, MessageId: relayed.MessageId, ClockBefore: clockBefore, Clock: currentClock, Description: message.Message})
+		end()
+
+		fmt.Println(fmt.Sprintf("[SERVER-VC%v] Message of client %d relayed to %s: '%s'", relayed.Clock, message.ClientId, link.Peer.Node, message.Message))
+		link.Queue.Push(relayed)
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"vector-clock/client"
+)
+
+// function to update the matrix clock with what a client knew when it sent a message, including the messages
+// it had delivered itself, and to garbage collect the messages every client is now known to have delivered.
+// Must be called with the lock held
+func (s *Server) updateKnowledge(clientId int, msg client.Message) {
+	if s.Knowledge == nil {
+		return
+	}
+
+	s.Knowledge.Merge(msg.Matrix)
+	s.Knowledge.Merge(client.MatrixClock{clientId: msg.Timestamp})
+	s.Knowledge.Retire(s.Retired) // the client may still carry the rows of clients that have left
+	s.collectGarbage()
+}
+
+// function to drop the messages from the history that no client will ask to be redelivered again,
+// since every client in the system is known to have delivered them. Must be called with the lock held
+func (s *Server) collectGarbage() {
+	clientIds := s.clientIds(ServerId)
+	for senderId, messages := range s.History{
+		stable := s.Knowledge.Stable(senderId, clientIds)
+		collected := min(stable - s.HistoryBase[senderId], len(messages))
+		if collected <= 0 {
+			continue
+		}
+
+		s.History[senderId] = slices.Clone(messages[collected:])
+		s.HistoryBase[senderId] += collected
+		fmt.Println(fmt.Sprintf("[SERVER-VC%v] Garbage collected %d messages of client %d from the history, every client has delivered them. Messages kept: %d", s.Clock.Load(), collected, senderId, len(s.History[senderId])))
+	}
+}
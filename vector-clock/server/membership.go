@@ -0,0 +1,142 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"vector-clock/client"
+	"vector-clock/itc"
+	"vector-clock/schedule"
+	"vector-clock/trace"
+)
+
+// function to add a new client to the system while it is running.
+// The returned client still has to be started by the caller.
+func (s *Server) Join() *client.Client {
+	return s.join(nil)
+}
+
+// function to add a client forked off by another client, which gives the new client half of its interval tree clock id.
+// The returned client still has to be started by the caller.
+func (s *Server) JoinFork(parent *client.Client) *client.Client {
+	fork := parent.Fork()
+	if fork == nil {
+		return s.join(nil)
+	}
+	joined := s.join(fork.Stamp)
+	joined.ReceiveFork(*fork)
+	return joined
+}
+
+// function to add a new client, with the given stamp or with half of the server's if it is nil
+func (s *Server) join(stamp *itc.Stamp) *client.Client {
+	end := s.lockRunning(ServerId, client.Message{}) // a crashed server cannot let new clients in until it has restarted
+
+	id := s.NextId
+	s.NextId += 1
+
+	sendChannel := make(chan client.Message) // channel for the server to send messages to the client
+	receiveChannel := make(chan client.Message) // channel for the client to send messages to the server
+	s.SendChannels[id] = sendChannel
+	s.ReceiveChannels[id] = receiveChannel
+	s.Departed[id] = make(chan struct{})
+	s.Pending[id] = make(map[int]*PendingMessage)
+	s.Queues[id] = NewOutboundQueue(s.QueueCapacity, s.OverflowPolicy)
+	go s.sendQueued(s.Queues[id], sendChannel, s.Departed[id])
+
+	var encoder *client.DiffEncoder
+	var decoder *client.DiffDecoder
+	if s.Compress {
+		s.Encoders[id] = client.NewDiffEncoder()
+		encoder, decoder = client.NewDiffEncoder(), client.NewDiffDecoder()
+	}
+
+	// Messages broadcast before the client joined will never be delivered to it,
+	// so they are counted as delivered to keep them out of the causal delivery condition
+	delivered := make(client.VectorClock)
+	for senderId, messages := range s.History{
+		delivered[senderId] = s.HistoryBase[senderId] + len(messages)
+	}
+
+	var knowledge client.MatrixClock
+	if s.Knowledge != nil {
+		s.Knowledge[id] = delivered.Copy()
+		knowledge = s.Knowledge.Copy()
+	}
+
+	s.tickStamp(nil)
+	if s.Stamp != nil && stamp == nil {
+		kept, forked := s.Stamp.Fork()
+		s.Stamp, stamp = &kept, &forked
+	}
+	retired := maps.Clone(s.Retired)
+	s.Lock.Unlock()
+
+	clockBefore, currentClock := s.tick()
+	fmt.Println(fmt.Sprintf("[SERVER-VC%v] Client %d joined the system", currentClock, id))
+	s.Trace.Record(trace.Event{Node: s.Node, Type: trace.INTERNAL, ClockBefore: clockBefore, Clock: currentClock, Description: fmt.Sprintf("client %d joined", id)})
+	end()
+
+	go s.handleClientChannels(id, receiveChannel)
+
+	return &client.Client{
+		Id: id,
+		Server: s.Node,
+		SendChannel: receiveChannel,
+		ReceiveChannel: sendChannel,
+		Clock: make(client.VectorClock), // every client starts off with a logical clock of 0
+		Delivered: delivered,
+		Seen: make(client.VectorClock),
+		Knowledge: knowledge,
+		Stamp: stamp,
+		Retired: retired,
+		Quit: make(chan struct{}),
+		SeqAhead: make(map[int]bool),
+		Trace: s.Trace,
+		Schedule: s.Schedule,
+		Encoder: encoder,
+		Decoder: decoder,
+	}
+}
+
+// function to remove a client from the system while it is running.
+// Called once the client has sent its LEAVE message, after every message before it has been handled.
+func (s *Server) Leave(clientId int) {
+	// retiring the entry changes the clock the steps in progress update, so the leave waits for them
+	s.Steps.Lock()
+	s.Lock.Lock()
+	channel, departed, queue := s.SendChannels[clientId], s.Departed[clientId], s.Queues[clientId]
+	delete(s.SendChannels, clientId)
+	delete(s.Queues, clientId)
+	delete(s.ReceiveChannels, clientId)
+	delete(s.Departed, clientId)
+	delete(s.Pending, clientId)
+	delete(s.NextSeq, clientId)
+	delete(s.Encoders, clientId)
+	s.unsubscribe(clientId)
+
+	s.Retired[clientId] = true
+	delete(s.Knowledge, clientId)
+	s.tickStamp(nil)
+	recipients := s.clientIds(clientId)
+	s.Lock.Unlock()
+	clockBefore, currentClock := s.Clock.Update(func(clock client.VectorClock) {
+		clock.Retire(s.Retired)
+		clock[s.Id] += 1
+	})
+	s.Trace.Record(trace.Event{Node: s.Node, Type: trace.INTERNAL, ClockBefore: clockBefore, Clock: currentClock, Description: fmt.Sprintf("client %d left", clientId)})
+	s.Steps.Unlock()
+
+	fmt.Println(fmt.Sprintf("[SERVER-VC%v] Client %d left the system, its entry has been retired from the vector clock", currentClock, clientId))
+
+	// Confirming the leave so the client stops receiving, then abandoning any forwards still waiting on it
+	channel <- client.Message{Type: client.LEAVE, Clock: currentClock, ClientId: clientId}
+	close(departed)
+	queue.Close()
+
+	// Announcing the leave so the other clients retire the id from their vector clocks as well
+	for _, i := range recipients{
+		if currentClock, ok := s.forward(i, client.Message{Type: client.LEAVE, ClientId: clientId}, schedule.FORWARD); ok {
+			fmt.Println(fmt.Sprintf("[SERVER-VC%v] Client %d has been notified that client %d left", currentClock, i, clientId))
+		}
+	}
+}
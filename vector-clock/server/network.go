@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/rand"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	CONSTANT    = "constant"    // every message takes MinLatencyMs
+	UNIFORM     = "uniform"     // latency is uniformly distributed between MinLatencyMs and MaxLatencyMs
+	EXPONENTIAL = "exponential" // latency is MinLatencyMs plus an exponentially distributed delay with mean MeanLatencyMs, capped at MaxLatencyMs if set
+)
+
+// NetworkModel decides what happens to every message the server forwards to a client
+type NetworkModel interface {
+	Transmit(clientId int) Transmission
+}
+
+// Outcome of forwarding a single message over a link
+type Transmission struct {
+	Dropped bool
+	Delays []time.Duration // latency of every copy that gets delivered, more than one if the message is duplicated
+}
+
+// Behaviour of the link between the server and a client
+type LinkConfig struct {
+	DropRate float64 `json:"drop_rate"` // probability that a message is lost
+	Distribution string `json:"distribution"` // CONSTANT | UNIFORM | EXPONENTIAL
+	MinLatencyMs int `json:"min_latency_ms"`
+	MaxLatencyMs int `json:"max_latency_ms"`
+	MeanLatencyMs int `json:"mean_latency_ms"`
+	ReorderRate float64 `json:"reorder_rate"` // probability that a message is held back for ReorderDelayMs so later messages overtake it
+	ReorderDelayMs int `json:"reorder_delay_ms"`
+	DuplicateRate float64 `json:"duplicate_rate"` // probability that a message is delivered twice
+}
+
+// Configuration of the whole network, read from a JSON file
+type NetworkConfig struct {
+	Seed int64 `json:"seed"` // seed of the random number generator, 0 picks one from the current time
+	Default LinkConfig `json:"default"`
+	Links map[string]LinkConfig `json:"links"` // link configurations keyed by client id, overriding the default
+}
+
+// Network model simulating every link according to its configuration.
+// All randomness comes from one seeded generator so a run can be reproduced.
+type SimulatedNetwork struct {
+	Config NetworkConfig
+	rng *rand.Rand
+	lock sync.Mutex
+}
+
+// Network that drops half of the forwarded messages, as the original coin flip did
+func DefaultNetworkConfig() NetworkConfig {
+	return NetworkConfig{Default: LinkConfig{DropRate: 0.5, Distribution: CONSTANT}}
+}
+
+// Reading the network configuration from a JSON file, rejecting a link whose latency cannot be drawn as configured
+func LoadNetworkConfig(path string) (NetworkConfig, error) {
+	config := DefaultNetworkConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, err
+	}
+
+	if err := config.Default.validate(); err != nil {
+		return config, fmt.Errorf("link default: %v", err)
+	}
+	for _, id := range slices.Sorted(maps.Keys(config.Links)){
+		if err := config.Links[id].validate(); err != nil {
+			return config, fmt.Errorf("link %s: %v", id, err)
+		}
+	}
+	return config, nil
+}
+
+// Checking that the link has a known latency distribution and that its maximum latency is not below its minimum.
+// A link without a distribution has a constant latency
+func (link LinkConfig) validate() error {
+	switch link.Distribution {
+	case "", CONSTANT:
+	case UNIFORM:
+		if link.MaxLatencyMs < link.MinLatencyMs {
+			return fmt.Errorf("the maximum latency %dms is below the minimum latency %dms", link.MaxLatencyMs, link.MinLatencyMs)
+		}
+	case EXPONENTIAL:
+		// the maximum only caps the latency if it is set
+		if link.MaxLatencyMs > 0 && link.MaxLatencyMs < link.MinLatencyMs {
+			return fmt.Errorf("the maximum latency %dms is below the minimum latency %dms", link.MaxLatencyMs, link.MinLatencyMs)
+		}
+	default:
+		return fmt.Errorf("unknown latency distribution %q, it must be %s, %s or %s", link.Distribution, CONSTANT, UNIFORM, EXPONENTIAL)
+	}
+	return nil
+}
+
+func NewSimulatedNetwork(config NetworkConfig) *SimulatedNetwork {
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	return &SimulatedNetwork{Config: config, rng: rand.New(rand.NewSource(config.Seed))}
+}
+
+func (n *SimulatedNetwork) Transmit(clientId int) Transmission {
+	link := n.link(clientId)
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.rng.Float64() < link.DropRate {
+		return Transmission{Dropped: true}
+	}
+
+	copies := 1
+	if n.rng.Float64() < link.DuplicateRate {
+		copies = 2
+	}
+
+	var transmission Transmission
+	for range copies {
+		delay := n.latency(link)
+		if n.rng.Float64() < link.ReorderRate {
+			delay += time.Duration(link.ReorderDelayMs) * time.Millisecond
+		}
+		transmission.Delays = append(transmission.Delays, delay)
+	}
+	return transmission
+}
+
+// Finding the configuration of the link to a client
+func (n *SimulatedNetwork) link(clientId int) LinkConfig {
+	if link, ok := n.Config.Links[strconv.Itoa(clientId)]; ok {
+		return link
+	}
+	return n.Config.Default
+}
+
+// Drawing the latency of a message from the link's distribution. Must be called with the lock held
+func (n *SimulatedNetwork) latency(link LinkConfig) time.Duration {
+	latencyMs := float64(link.MinLatencyMs)
+
+	switch link.Distribution {
+	case UNIFORM:
+		latencyMs += n.rng.Float64() * float64(link.MaxLatencyMs - link.MinLatencyMs)
+	case EXPONENTIAL:
+		latencyMs += n.rng.ExpFloat64() * float64(link.MeanLatencyMs)
+		if link.MaxLatencyMs > 0 {
+			latencyMs = min(latencyMs, float64(link.MaxLatencyMs))
+		}
+	}
+
+	return time.Duration(latencyMs * float64(time.Millisecond))
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"vector-clock/client"
+	"sync"
+)
+
+// What a queue does with a message that arrives when it is full
+const (
+	BLOCK       = "block"       // the forward waits until the recipient makes room
+	DROP_OLDEST = "drop-oldest" // the message that has waited longest is dropped to make room
+	DROP_NEWEST = "drop-newest" // the arriving message is dropped
+)
+
+var OverflowPolicies = []string{BLOCK, DROP_OLDEST, DROP_NEWEST}
+
+// OutboundQueue holds the messages forwarded to one client until its sender goroutine puts them on the client's channel,
+// so a slow client only holds up its own messages. Messages dropped on overflow are retransmitted like the ones the network drops.
+type OutboundQueue struct {
+	Capacity int
+	Policy string // BLOCK | DROP_OLDEST | DROP_NEWEST
+	Messages []client.Message
+	MaxDepth int // largest number of messages that have waited in the queue at once
+	Overflowed int // messages dropped because the queue was full
+	Sent int // messages put on the client's channel
+	Closed bool // the client has left, so the queue takes no more messages
+	Lock sync.Mutex
+	changed *sync.Cond // signalled whenever a message is added or removed, or the queue is closed
+}
+
+// depth of a queue at one point in time
+type QueueMetrics struct {
+	ClientId int
+	Depth int
+	MaxDepth int
+	Overflowed int
+	Sent int
+}
+
+func NewOutboundQueue(capacity int, policy string) *OutboundQueue {
+	queue := &OutboundQueue{Capacity: capacity, Policy: policy}
+	queue.changed = sync.NewCond(&queue.Lock)
+	return queue
+}
+
+// adds a message to the queue, applying the overflow policy if it is full. Returns the message that was dropped
+// to make room or refused, nil if none was, and false if the queue is closed
+func (q *OutboundQueue) Push(message client.Message) (*client.Message, bool) {
+	q.Lock.Lock()
+	defer q.Lock.Unlock()
+
+	for q.Policy == BLOCK && len(q.Messages) >= q.Capacity && !q.Closed {
+		q.changed.Wait()
+	}
+	if q.Closed {
+		return nil, false
+	}
+
+	var dropped *client.Message
+	if len(q.Messages) >= q.Capacity {
+		q.Overflowed += 1
+		if q.Policy == DROP_NEWEST {
+			return &message, true
+		}
+		oldest := q.Messages[0]
+		dropped = &oldest
+		q.Messages = q.Messages[1:]
+	}
+	q.Messages = append(q.Messages, message)
+	q.MaxDepth = max(q.MaxDepth, len(q.Messages))
+	q.changed.Broadcast()
+	return dropped, true
+}
+
+// takes the oldest message off the queue, waiting for one if it is empty. Returns false once the queue is closed
+func (q *OutboundQueue) Pop() (client.Message, bool) {
+	q.Lock.Lock()
+	defer q.Lock.Unlock()
+
+	for len(q.Messages) == 0 && !q.Closed {
+		q.changed.Wait()
+	}
+	if q.Closed {
+		return client.Message{}, false
+	}
+
+	message := q.Messages[0]
+	q.Messages = q.Messages[1:]
+	q.Sent += 1
+	q.changed.Broadcast()
+	return message, true
+}
+
+// closes the queue, discarding the messages still in it and releasing the forwards waiting for room
+func (q *OutboundQueue) Close() {
+	q.Lock.Lock()
+	defer q.Lock.Unlock()
+
+	q.Closed = true
+	q.Messages = nil
+	q.changed.Broadcast()
+}
+
+// empties the queue, returning the messages that were in it
+func (q *OutboundQueue) Clear() []client.Message {
+	q.Lock.Lock()
+	defer q.Lock.Unlock()
+
+	messages := q.Messages
+	q.Messages = nil
+	q.changed.Broadcast()
+	return messages
+}
+
+func (q *OutboundQueue) Metrics(clientId int) QueueMetrics {
+	q.Lock.Lock()
+	defer q.Lock.Unlock()
+
+	return QueueMetrics{ClientId: clientId, Depth: len(q.Messages), MaxDepth: q.MaxDepth, Overflowed: q.Overflowed, Sent: q.Sent}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"time"
+	"vector-clock/client"
+)
+
+const (
+	AckTimeout = 2 * time.Second // how long the server waits for an acknowledgement before retransmitting
+	RetransmitInterval = 500 * time.Millisecond // how often the server looks for messages to retransmit
+)
+
+// forwarded message waiting for an acknowledgement from its recipient
+type PendingMessage struct {
+	Message client.Message
+	SentAt time.Time
+	Attempts int
+	Dropped bool // the last transmission was dropped by the network or by a full queue, so the message is not on its way until it is retransmitted
+}
+
+// function to retransmit every forwarded message that has not been acknowledged in time.
+// A retransmission carries the clock of the original forward since it is the same send event
+// as far as the vector clock is concerned, so it does not increment the server's clock.
+func (s *Server) RetransmitMessages(){
+	for{
+		time.Sleep(RetransmitInterval)
+
+		s.Lock.Lock()
+		expired := make(map[int][]PendingMessage)
+		for clientId, pendingMessages := range s.Pending{
+			for _, pending := range pendingMessages{
+				if time.Since(pending.SentAt) >= AckTimeout {
+					pending.SentAt = time.Now()
+					pending.Attempts += 1
+					expired[clientId] = append(expired[clientId], *pending)
+				}
+			}
+		}
+		s.Lock.Unlock()
+
+		for clientId, messages := range expired{
+			slices.SortFunc(messages, func(a, b PendingMessage) int { return a.Message.Seq - b.Message.Seq })
+			for _, pending := range messages{
+				message := pending.Message
+				fmt.Println(fmt.Sprintf("[SERVER-VC%v] No acknowledgement from client %d for message %d, retransmitting: '%s'", message.Clock, clientId, message.Seq, message.Message))
+				s.transmit(clientId, message, pending.Attempts)
+			}
+		}
+	}
+}
+
+// function to stop retransmitting a message once its recipient has acknowledged it
+func (s *Server) acknowledge(clientId int, seq int){
+	s.Lock.Lock()
+	pending, ok := s.Pending[clientId][seq]
+	delete(s.Pending[clientId], seq)
+	s.Lock.Unlock()
+
+	if ok && pending.Attempts > 1 {
+		fmt.Println(fmt.Sprintf("[SERVER-VC%v] Message %d acknowledged by client %d after %d transmissions", pending.Message.Clock, seq, clientId, pending.Attempts))
+	}
+}
+
+// function to count the forwarded messages still waiting for an acknowledgement, to tell when a run has settled
+func (s *Server) Unacknowledged() int {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	count := 0
+	for _, messages := range s.Pending{
+		count += len(messages)
+	}
+	return count
+}
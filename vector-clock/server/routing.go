@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"vector-clock/client"
+)
+
+// function to subscribe a client to a topic, so it receives the messages addressed to the topic from then on
+func (s *Server) Subscribe(clientId int, topic string) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	if s.Topics[topic] == nil {
+		s.Topics[topic] = make(map[int]bool)
+	}
+	s.Topics[topic][clientId] = true
+	fmt.Println(fmt.Sprintf("[SERVER-VC%v] Client %d subscribed to topic %s", s.Clock.Load(), clientId, topic))
+}
+
+// function to list the clients a message is routed to: the subscribers of its topic, the clients it is addressed to
+// or every client, never including its sender. In a federation only the server's own clients are listed,
+// the other regions route the message to theirs. Must be called with the lock held
+func (s *Server) recipients(message client.Message) []int {
+	if !message.IsDirected() {
+		return s.clientIds(message.ClientId)
+	}
+
+	ids := make([]int, 0)
+	if message.Topic != "" {
+		for id := range s.Topics[message.Topic]{
+			if id != message.ClientId {
+				ids = append(ids, id)
+			}
+		}
+	}
+	for _, id := range message.To{
+		if s.Remote[id] {
+			continue // the server of its region routes the message to it
+		}
+		if _, ok := s.SendChannels[id]; !ok {
+			fmt.Println(fmt.Sprintf("[SERVER-VC%v] Message of client %d not routed to client %d, which is not in the system: '%s'", s.Clock.Load(), message.ClientId, id, message.Message))
+		} else if id != message.ClientId {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	if len(ids) == 0 && len(s.Links) == 0 {
+		fmt.Println(fmt.Sprintf("[SERVER-VC%v] Message of client %d for %s has no recipient: '%s'", s.Clock.Load(), message.ClientId, message.Recipients(), message.Message))
+	}
+	return ids
+}
+
+// function to remove a client from every topic it subscribed to. Must be called with the lock held
+func (s *Server) unsubscribe(clientId int) {
+	for _, subscribers := range s.Topics{
+		delete(subscribers, clientId)
+	}
+}
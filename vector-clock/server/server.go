@@ -3,77 +3,390 @@ package server
 import (
 	"fmt"
 	"vector-clock/client"
-	"math/rand"
+	"vector-clock/clock"
+	"vector-clock/itc"
+	"vector-clock/schedule"
+	"vector-clock/trace"
+	"slices"
 	"sync"
 	"time"
 )
 
+const (
+	ServerId = -1 // id of the server's entry in the vector clocks
+)
 
 type Server struct {
-	Clock []int
-	SendChannels []chan client.Message
-	ReceiveChannels []chan client.Message
+	Id int // id of the server's entry in the vector clocks: ServerId, or ServerId - k for the server of region k in a federation
+	Node string // name of the server in the trace
+	Clock clock.Vector // kept in a mutex or copied on write, and updated outside Lock so only the backend serializes the updates
+	SendChannels map[int]chan client.Message
+	ReceiveChannels map[int]chan client.Message
+	Departed map[int]chan struct{} // closed when a client leaves so forwards still waiting on it are abandoned
+	Queues map[int]*OutboundQueue // messages waiting to be put on each client's channel
+	QueueCapacity int // number of messages each queue holds before its overflow policy applies
+	OverflowPolicy string // what a full queue does with the next message: BLOCK | DROP_OLDEST | DROP_NEWEST
+	History map[int][]client.Message // messages received from each client that some client may still need, kept for redelivery
+	HistoryBase map[int]int // messages of each client garbage collected from the front of its history
+	Stamp *itc.Stamp // interval tree clock of the server, nil unless interval tree clocks are enabled
+	Knowledge client.MatrixClock // what the server knows about the messages every client has delivered, nil unless matrix clocks are enabled
+	Retired map[int]bool // ids of clients that have left the system
+	Topics map[string]map[int]bool // clients subscribed to each topic
+	NextId int // id given to the next client that joins
+	Network NetworkModel // decides how every forwarded message travels to its recipient
+	NextSeq map[int]int // last sequence number used on the link to each client
+	Pending map[int]map[int]*PendingMessage // forwarded messages not acknowledged yet, by client and sequence number
+	Trace *trace.Recorder // records the server's events, nil if tracing is disabled
+	Schedule *schedule.Schedule // records the order of the server's steps or replays a recorded one, nil if it does neither
+	Compress bool // compresses the clocks on every link with the Singhal–Kshemkalyani differential technique
+	Encoders map[int]*client.DiffEncoder // compress the clocks forwarded to each client
+	Recovery string // how the clock is recovered after a crash: CHECKPOINT | MAX
+	CheckpointFile string // file the clock is checkpointed to when it is recovered from checkpoints
+	Down bool // the server has crashed and not restarted yet
+	Recovering bool // the server has restarted and waits for the next message of every client to recover its clock
+	Awaiting map[int]bool // clients whose next message the server still waits for to recover its clock
+	Restarted chan struct{} // closed when the server restarts after a crash, and again once it has recovered its clock from the clients
+	Crashes int // number of times the server has crashed
+	ClockBeforeCrash client.VectorClock // clock the server had when it last crashed, kept for the trace and the logs only
+	Validation *client.Validation // limits the clocks received from the clients have to respect, nil if they are not validated
+	Quarantined map[int]time.Time // clients whose messages are rejected, with the time their quarantine is over or the zero time if it never is
+	Rejected int // messages rejected because of their clock or their sender's quarantine
+	Links []*Link // links to the neighbouring servers of a federation
+	Relayed chan client.Message // messages relayed by the neighbouring servers of a federation
+	Remote map[int]bool // ids of the servers and clients of the other regions of a federation
+	Steps sync.RWMutex // held by the steps that update the clock, see beginStep. Always taken before Lock
 	Lock sync.Mutex
 }
 
-// function to receive messages from client
-func (s *Server) ReceiveMessage(){
-	for i := range s.ReceiveChannels{
-		go s.handleClientChannels(i)
+// function to start a step that updates the clock, returning the function that ends it. The steps of a server
+// run at once and only the clock backend orders their updates, unless the run is traced, scheduled or compresses
+// its clocks: those need every step to see the clock the previous one left, so the steps run one at a time.
+// A crash, a recovery or a leave replaces the clock or retires one of its entries, so it waits for the steps in
+// progress by taking Steps for writing
+func (s *Server) beginStep() func() {
+	if s.Trace != nil || s.Schedule != nil || s.Compress {
+		s.Steps.Lock()
+		return s.Steps.Unlock
 	}
+	s.Steps.RLock()
+	return s.Steps.RUnlock
 }
 
 // function to handle all client channels
-func (s *Server) handleClientChannels(clientId int){
+func (s *Server) handleClientChannels(clientId int, channel chan client.Message){
+	var decoder *client.DiffDecoder
+	if s.Compress {
+		decoder = client.NewDiffDecoder()
+	}
+
 	for{
-		msg := <- s.ReceiveChannels[clientId]
-		
-		if client.CausalityDetection(msg.Clock, s.Clock) {
-			fmt.Println(fmt.Sprintf("[SERVER-VC%v] Potential Causality Violation detected for message: '%s'. Message Clock: %v", s.Clock, msg.Message, msg.Clock))
+		msg := <- channel
+
+		if msg.Type == client.ACK {
+			s.acknowledge(clientId, msg.Seq)
+			continue
 		}
 
-		s.Lock.Lock()
-		s.Clock = client.VectorMAX(s.Clock, msg.Clock) // updating the logical clock by finding the maximum between the two clock values
-		s.Clock[len(s.Clock) - 1] += 1
-		fmt.Println(fmt.Sprintf("[SERVER-VC%v] Message receieved: '%s'", s.Clock, msg.Message))
-		s.Lock.Unlock()
+		// A compressed clock can only be rebuilt once every message encoded before it on the link has arrived
+		for _, msg := range decoder.Decode(msg){
+			if left := s.handleMessage(clientId, msg); left {
+				return
+			}
+		}
+	}
+}
+
+// function to handle a message from a client, returns true if the client has left the system
+func (s *Server) handleMessage(clientId int, msg client.Message) bool {
+	if !s.validate(clientId, msg) {
+		if msg.Type == client.LEAVE {
+			// a client still leaves when its clock is rejected, the clock just isn't merged
+			s.Leave(clientId)
+			return true
+		}
+		if msg.Type == client.MESSAGE && !msg.IsDirected() {
+			s.bury(clientId, msg)
+		}
+		return false
+	}
+
+	step := s.Schedule.Await(schedule.Step{Node: s.Node, Kind: schedule.RECEIVE, MessageId: msg.MessageId})
+	// A crashed server only handles the message once it has restarted
+	end := s.lockRunning(clientId, msg)
+	if client.CausalityDetection(msg.Clock, s.Clock.Load()) {
+		fmt.Println(fmt.Sprintf("[SERVER-VC%v] Potential Causality Violation detected for message: '%s'. Message Clock: %v", s.Clock.Load(), msg.Message, msg.Clock))
+	}
+	s.tickStamp(msg.Stamp)
+	if msg.Type != client.REDELIVER && msg.Type != client.LEAVE && !msg.IsDirected() {
+		// only broadcasts are delivered in causal order, so only they can be requested again
+		s.History[msg.ClientId] = append(s.History[msg.ClientId], msg)
+	}
+	if msg.Type != client.LEAVE {
+		s.updateKnowledge(clientId, msg)
+	}
+	s.Lock.Unlock()
 
+	// only a leave changes Retired, and it waits for the steps in progress
+	clockBefore, currentClock := s.Clock.Update(func(clock client.VectorClock) {
+		client.VectorMAX(clock, msg.Clock) // updating the logical clock by finding the maximum between the two clock values
+		clock.Retire(s.Retired)
+		clock[s.Id] += 1
+	})
+	description := msg.Message
+	switch msg.Type {
+	case client.REDELIVER:
+		description = "redelivery request"
+		fmt.Println(fmt.Sprintf("[SERVER-VC%v] Redelivery request receieved from client %d. Delivered: %v", currentClock, msg.ClientId, msg.Timestamp))
+	case client.LEAVE:
+		description = "leave"
+		fmt.Println(fmt.Sprintf("[SERVER-VC%v] Leave request receieved from client %d", currentClock, msg.ClientId))
+	default:
+		fmt.Println(fmt.Sprintf("[SERVER-VC%v] Message receieved for %s: '%s'", currentClock, msg.Recipients(), msg.Message))
+	}
+	s.Trace.Record(trace.Event{Node: s.Node, Type: trace.RECEIVE, Peer: trace.Client(clientId), MessageId: msg.MessageId, ClockBefore: clockBefore, Clock: currentClock, Description: description})
+	s.Schedule.Done(step)
+	end()
+
+	switch msg.Type {
+	case client.REDELIVER:
+		s.redeliverMessages(msg)
+	case client.LEAVE:
+		s.Leave(clientId)
+		return true
+	default:
 		if !msg.IsEmpty() {
-			// send to all clients which don't have id as clientId
+			// send to the clients the message is routed to, never back to its sender
 			s.sendMessage(msg)
 		}
 	}
+	return false
 }
 
-// function to send message to clients except the one who sent the message
+// function to send message to the clients it is routed to
 func (s *Server) sendMessage(message client.Message){
-	
-	if !s.coinFlip(){
+	s.relay(message)
+
+	s.Lock.Lock()
+	recipients := s.recipients(message)
+	s.Lock.Unlock()
+
+	received := message.MessageId
+	for _, i := range recipients{
+		step := s.Schedule.Await(schedule.Step{Node: s.Node, Kind: schedule.FORWARD, Peer: trace.Client(i), MessageId: received})
+		end := s.beginStep()
+		s.Lock.Lock()
+		pending, ok := s.Pending[i]
+		if !ok || s.Down {
+			// the client left in the meantime, or the server crashed and lost the message
+			s.Lock.Unlock()
+			end()
+			continue
+		}
+		s.NextSeq[i] += 1
+		message.Seq = s.NextSeq[i]
+		message.Matrix = s.Knowledge.Copy()
+		message.Stamp = s.tickStamp(nil)
+		s.Lock.Unlock()
+
+		clockBefore, currentClock := s.tick()
+		message.Clock = currentClock
+		message.MessageId = s.Trace.NewMessageId(s.Node)
+		s.Trace.Record(trace.Event{Node: s.Node, Type: trace.SEND, Peer: trace.Client(i), MessageId: message.MessageId, ClockBefore: clockBefore, Clock: currentClock, Description: message.Message})
+		s.Schedule.Done(step)
+
 		s.Lock.Lock()
-		s.Clock[len(s.Clock) - 1] += 1
-		currentClock := s.Clock
+		message = s.Encoders[i].Encode(message)
+		pending[message.Seq] = &PendingMessage{Message: message, SentAt: time.Now(), Attempts: 1}
 		s.Lock.Unlock()
+		end()
+
+		s.transmit(i, message, 1)
+	}
+}
 
-		fmt.Println(fmt.Sprintf("[SERVER-VC%v] Forwarding the message of client %d is dropped", currentClock, message.ClientId))
+// function to send a message over the link to a client, which may drop, delay or duplicate it
+func (s *Server) transmit(clientId int, message client.Message, attempt int){
+	// the network model decides whether the message reaches the client, how long it takes and how many copies arrive
+	transmission := s.decide(clientId, message, attempt)
+
+	s.Lock.Lock()
+	if pending, ok := s.Pending[clientId][message.Seq]; ok {
+		pending.Dropped = transmission.Dropped
+	}
+	s.Lock.Unlock()
+
+	if transmission.Dropped {
+		fmt.Println(fmt.Sprintf("[SERVER-VC%v] Forwarding the message of client %d to client %d is dropped", message.Clock, message.ClientId, clientId))
+		s.Trace.Record(trace.Event{Node: s.Node, Type: trace.DROP, Peer: trace.Client(clientId), MessageId: message.MessageId, ClockBefore: message.Clock.Copy(), Clock: message.Clock.Copy(), Description: message.Message})
 		return
 	}
 
-	for i, channel := range s.SendChannels{
-		if i != message.ClientId{
+	for copyIndex, delay := range transmission.Delays{
+		if !s.deliver(clientId, message, delay) {
+			continue
+		}
+		if copyIndex > 0 {
+			fmt.Println(fmt.Sprintf("[SERVER-VC%v] Message duplicated to client %d with a latency of %v: '%s'", message.Clock, clientId, delay, message.Message))
+		} else if delay > 0 {
+			fmt.Println(fmt.Sprintf("[SERVER-VC%v] Message forwarded to client %d with a latency of %v: '%s'", message.Clock, clientId, delay, message.Message))
+		} else {
+			fmt.Println(fmt.Sprintf("[SERVER-VC%v] Message forwarded to client %d: '%s'", message.Clock, clientId, message.Message))
+		}
+	}
+}
 
-			s.Lock.Lock()
-			s.Clock[len(s.Clock) - 1] += 1
-			currentClock := s.Clock
-			s.Lock.Unlock()
-			
-			channel <- client.Message{currentClock, message.Message, message.ClientId}
-			fmt.Println(fmt.Sprintf("[SERVER-VC%v] Message forwarded to client %d: '%s'", s.Clock, i, message.Message))
+// function to resend every message the requesting client has not delivered yet
+func (s *Server) redeliverMessages(request client.Message){
+	s.Lock.Lock()
+	senders := make([]int, 0, len(s.History))
+	for senderId := range s.History{
+		if senderId != request.ClientId {
+			senders = append(senders, senderId)
+		}
+	}
+	s.Lock.Unlock()
+	slices.Sort(senders)
+
+	for _, senderId := range senders{
+		s.Lock.Lock()
+		// the messages the client has delivered are never garbage collected before it has delivered them
+		start := max(request.Timestamp[senderId] - s.HistoryBase[senderId], 0)
+		missing := slices.Clone(s.History[senderId][min(start, len(s.History[senderId])):])
+		s.Lock.Unlock()
+
+		for _, message := range missing{
+			if currentClock, ok := s.forward(request.ClientId, message, schedule.REDELIVER); ok {
+				fmt.Println(fmt.Sprintf("[SERVER-VC%v] Message of client %d redelivered to client %d: '%s'", currentClock, senderId, request.ClientId, message.Message))
+			}
+		}
+	}
+}
+
+// function to forward a message to one client with the current server clock. The kind of step tells a redelivery
+// from the server's history apart from a new message. The message waits for an acknowledgement like the messages the
+// server routes, so it is retransmitted if a full queue drops it
+func (s *Server) forward(clientId int, message client.Message, kind string) (client.VectorClock, bool) {
+	step := s.Schedule.Await(schedule.Step{Node: s.Node, Kind: kind, Peer: trace.Client(clientId), MessageId: message.MessageId})
+	end := s.beginStep()
+	s.Lock.Lock()
+	pending, ok := s.Pending[clientId]
+	if !ok || s.Down {
+		// the client left in the meantime, or the server crashed and lost the message
+		s.Lock.Unlock()
+		end()
+		return nil, false
+	}
+	s.NextSeq[clientId] += 1
+	message.Seq = s.NextSeq[clientId]
+	message.Matrix = s.Knowledge.Copy()
+	message.Stamp = s.tickStamp(nil)
+	s.Lock.Unlock()
+
+	clockBefore, currentClock := s.tick()
+	message.Clock = currentClock
+	message.MessageId = s.Trace.NewMessageId(s.Node)
+	description := message.Message
+	if message.Type == client.LEAVE {
+		description = fmt.Sprintf("client %d left", message.ClientId)
+	}
+	s.Trace.Record(trace.Event{Node: s.Node, Type: trace.SEND, Peer: trace.Client(clientId), MessageId: message.MessageId, ClockBefore: clockBefore, Clock: currentClock, Description: description})
+	s.Schedule.Done(step)
+
+	s.Lock.Lock()
+	message = s.Encoders[clientId].Encode(message)
+	pending[message.Seq] = &PendingMessage{Message: message, SentAt: time.Now(), Attempts: 1}
+	s.Lock.Unlock()
+	end()
+
+	return message.Clock, s.deliver(clientId, message, 0)
+}
+
+// function to add a message to a client's queue after the given delay.
+// The delivery is abandoned if the client leaves before it receives the message.
+func (s *Server) deliver(clientId int, message client.Message, delay time.Duration) bool {
+	s.Lock.Lock()
+	queue := s.Queues[clientId]
+	s.Lock.Unlock()
+	if queue == nil {
+		return false
+	}
+
+	if delay > 0 {
+		// the message is in transit while the server carries on
+		go func() {
+			time.Sleep(delay)
+			s.enqueue(clientId, queue, message)
+		}()
+		return true
+	}
+	return s.enqueue(clientId, queue, message)
+}
+
+// function to add a message to a client's queue, returns false if the message is not in the queue.
+// A message dropped because the queue is full is retransmitted once its acknowledgement times out.
+func (s *Server) enqueue(clientId int, queue *OutboundQueue, message client.Message) bool {
+	dropped, ok := queue.Push(message)
+	if dropped != nil {
+		s.Lock.Lock()
+		if pending, ok := s.Pending[clientId][dropped.Seq]; ok {
+			pending.Dropped = true
+		}
+		s.Lock.Unlock()
+
+		fmt.Println(fmt.Sprintf("[SERVER-VC%v] Queue to client %d is full, message of client %d dropped (%s): '%s'", dropped.Clock, clientId, dropped.ClientId, queue.Policy, dropped.Message))
+		s.Trace.Record(trace.Event{Node: s.Node, Type: trace.DROP, Peer: trace.Client(clientId), MessageId: dropped.MessageId, ClockBefore: dropped.Clock.Copy(), Clock: dropped.Clock.Copy(), Description: dropped.Message})
+	}
+	return ok && (dropped == nil || queue.Policy != DROP_NEWEST)
+}
+
+// function run by the sender goroutine of a client, putting the messages of its queue on its channel one at a time
+func (s *Server) sendQueued(queue *OutboundQueue, channel chan client.Message, departed chan struct{}) {
+	for{
+		message, ok := queue.Pop()
+		if !ok {
+			return
+		}
+
+		select {
+		case channel <- message.OnWire():
+		case <-departed:
+			return
+		}
+	}
+}
+
+// function to report the depth of every client's queue
+func (s *Server) QueueMetrics() []QueueMetrics {
+	s.Lock.Lock()
+	ids := s.clientIds(ServerId)
+	queues := make([]*OutboundQueue, len(ids))
+	for i, id := range ids{
+		queues[i] = s.Queues[id]
+	}
+	s.Lock.Unlock()
+
+	metrics := make([]QueueMetrics, len(ids))
+	for i, queue := range queues{
+		metrics[i] = queue.Metrics(ids[i])
+	}
+	return metrics
+}
+
+// function to list the ids of the clients in the system except the given one. Must be called with the lock held
+func (s *Server) clientIds(exceptId int) []int {
+	ids := make([]int, 0, len(s.SendChannels))
+	for id := range s.SendChannels{
+		if id != exceptId {
+			ids = append(ids, id)
 		}
 	}
+	slices.Sort(ids)
+	return ids
 }
 
-// coin flip to decide if the server should drop the message
-func (s *Server) coinFlip() bool{
-	rand.Seed(time.Now().UnixNano()) // Making sure this is random using a unique seed
-	return rand.Intn(2) == 1 // Generates random number from 0 to 1
+// function to increment the server's own entry of its clock, returns the clock before and after. Must be called in a step
+func (s *Server) tick() (client.VectorClock, client.VectorClock) {
+	return s.Clock.Update(func(clock client.VectorClock) {
+		clock[s.Id] += 1
+	})
 }
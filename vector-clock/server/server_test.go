@@ -0,0 +1,186 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"vector-clock/client"
+	"vector-clock/clock"
+)
+
+// numbers of synthetic clients sending messages to the server at once
+var benchmarkClients = []int{100, 1000, 2000}
+
+func BenchmarkServerMutex(b *testing.B) {
+	benchmarkServer(b, clock.MUTEX)
+}
+
+func BenchmarkServerCopyOnWrite(b *testing.B) {
+	benchmarkServer(b, clock.COPY_ON_WRITE)
+}
+
+// drives a server with synthetic clients, each sending its share of the messages to the next client as fast as the
+// server takes them and acknowledging what it receives, so each message costs the server one merge and one tick of a
+// clock with an entry for every client. The messages are addressed rather than broadcast, so the server does not keep
+// them for redelivery.
+// Reports the messages handled per second, the clock updates that had to wait for or retry after the update of another
+// goroutine, and the times any goroutine waited for a mutex held by another one
+func benchmarkServer(b *testing.B, backend string) {
+	// every contended lock acquisition is sampled, so the waits on any mutex of the server can be reported
+	runtime.SetMutexProfileFraction(1)
+	defer runtime.SetMutexProfileFraction(0)
+
+	// the server logs every message, which would measure the terminal rather than the server
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	os.Stdout = devNull
+	defer func() {
+		os.Stdout = stdout
+		devNull.Close()
+	}()
+
+	for _, clients := range benchmarkClients{
+		// the server and its clients are kept for every run of the benchmark, since every join is announced to every client
+		var bench *serverBench
+		b.Run(fmt.Sprintf("clients=%d", clients), func(b *testing.B) {
+			if bench == nil {
+				bench = newServerBench(backend, clients)
+			}
+			contendedBefore := bench.server.Clock.Contention()
+			_, waitsBefore := lockWait()
+			b.ResetTimer()
+			bench.send(b.N)
+			b.StopTimer()
+			_, waits := lockWait()
+
+			b.ReportMetric(float64(b.N) / b.Elapsed().Seconds(), "msgs/sec")
+			b.ReportMetric(float64(bench.server.Clock.Contention() - contendedBefore) / float64(b.N), "contended/msg")
+			b.ReportMetric(float64(waits - waitsBefore) / float64(b.N), "lock-waits/msg")
+		})
+	}
+}
+
+// server driven by synthetic clients
+type serverBench struct {
+	server *Server
+	clients []*client.Client
+	sent []int // messages sent by each client so far
+	delivered sync.WaitGroup
+}
+
+// function to start a server over a network that loses nothing, and to let the synthetic clients join it
+func newServerBench(backend string, clients int) *serverBench {
+	bench := &serverBench{
+		server: &Server{
+			Id: ServerId,
+			Node: "server",
+			Clock: clock.NewVector(backend),
+			SendChannels: make(map[int]chan client.Message),
+			ReceiveChannels: make(map[int]chan client.Message),
+			Departed: make(map[int]chan struct{}),
+			History: make(map[int][]client.Message),
+			HistoryBase: make(map[int]int),
+			Retired: make(map[int]bool),
+			Network: lossless{},
+			NextSeq: make(map[int]int),
+			Pending: make(map[int]map[int]*PendingMessage),
+			Encoders: make(map[int]*client.DiffEncoder),
+			Topics: make(map[string]map[int]bool),
+			Queues: make(map[int]*OutboundQueue),
+			QueueCapacity: math.MaxInt, // the clients acknowledge through the handlers forwarding to them, so a full queue could close a cycle of waits
+			OverflowPolicy: BLOCK,
+			Quarantined: make(map[int]time.Time),
+			Relayed: make(chan client.Message),
+			Remote: make(map[int]bool),
+		},
+		sent: make([]int, clients),
+	}
+
+	for range clients{
+		c := bench.server.Join()
+		bench.clients = append(bench.clients, c)
+		go func() {
+			for{
+				msg := <- c.ReceiveChannel
+				c.SendChannel <- client.Message{Type: client.ACK, Seq: msg.Seq, ClientId: c.Id}
+				bench.delivered.Done()
+			}
+		}()
+	}
+	return bench
+}
+
+// function to send n messages spread over the clients, and to wait until every one of them is delivered
+func (bench *serverBench) send(n int) {
+	clients := len(bench.clients)
+	bench.delivered.Add(n)
+	var sent sync.WaitGroup
+	for i, c := range bench.clients{
+		messages := n / clients
+		if i < n % clients {
+			messages += 1
+		}
+		sent.Add(1)
+		go func() {
+			defer sent.Done()
+			for range messages{
+				bench.sent[i] += 1
+				c.SendChannel <- client.Message{Type: client.MESSAGE, Clock: client.VectorClock{c.Id: bench.sent[i]}, Message: "benchmark", To: []int{(c.Id + 1) % clients}, ClientId: c.Id, Seq: bench.sent[i]}
+			}
+		}()
+	}
+	sent.Wait()
+	bench.delivered.Wait()
+}
+
+// network that delivers every message at once, so the benchmark measures the server rather than the simulated links
+type lossless struct{}
+
+func (lossless) Transmit(clientId int) Transmission {
+	return Transmission{Delays: []time.Duration{0}}
+}
+
+// function to read the total time goroutines have waited for a mutex held by another goroutine, and how many times
+// they did, from the mutex profile. Both only grow, so a measurement is the difference between two readings
+func lockWait() (time.Duration, int) {
+	var profile bytes.Buffer
+	pprof.Lookup("mutex").WriteTo(&profile, 1)
+
+	cyclesPerSecond := 0.0
+	cycles, count := 0.0, 0
+	scanner := bufio.NewScanner(&profile)
+	for scanner.Scan(){
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "cycles/second="); ok {
+			cyclesPerSecond, _ = strconv.ParseFloat(value, 64)
+			continue
+		}
+		// every sampled call stack starts with the cycles spent waiting and the number of waits
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[2] != "@" {
+			continue
+		}
+		delay, err1 := strconv.ParseFloat(fields[0], 64)
+		waits, err2 := strconv.Atoi(fields[1])
+		if err1 == nil && err2 == nil {
+			cycles += delay
+			count += waits
+		}
+	}
+	if cyclesPerSecond == 0 {
+		return 0, count
+	}
+	return time.Duration(cycles / cyclesPerSecond * float64(time.Second)), count
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"fmt"
+	"vector-clock/itc"
+)
+
+// function to register an event of the server in its interval tree clock, after joining the stamp of a message
+// it received. A client leaving sends its whole stamp, so joining it gives its id back to the server.
+// Returns the stamp to send in a message. Must be called with the lock held
+func (s *Server) tickStamp(received *itc.Stamp) *itc.Stamp {
+	if s.Stamp == nil {
+		return nil
+	}
+
+	stamp := *s.Stamp
+	if received != nil {
+		if !received.IsAnonymous() {
+			fmt.Println(fmt.Sprintf("[SERVER-ITC%v] Joined back the stamp %v of a client that is leaving", stamp, *received))
+		}
+		stamp = itc.Join(stamp, *received)
+	}
+	stamp = stamp.Event()
+	s.Stamp = &stamp
+
+	peek := stamp.Peek()
+	return &peek
+}
+
+// Number of ids retired from the vector clocks, which are never reused
+func (s *Server) RetiredCount() int {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	return len(s.Retired)
+}
+
+// Encoded size in bytes of the server's vector clock and of its interval tree clock
+func (s *Server) ClockSizes() (int, int) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	if s.Stamp == nil {
+		return s.Clock.Load().EncodedSize(), 0
+	}
+	return s.Clock.Load().EncodedSize(), s.Stamp.EncodedSize()
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"time"
+	"vector-clock/client"
+	"vector-clock/trace"
+)
+
+// function to check the clock of a message from a client before the server handles it, returns false if it is rejected.
+// The server knows which clients are in the system, so a clock with an entry for any other node is rejected.
+// A client whose clock is rejected is quarantined, and its messages are rejected until the quarantine is over.
+// The jump is not checked while the server has lost its clock in a crash, since there is nothing to compare it with
+func (s *Server) validate(clientId int, msg client.Message) bool {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	if s.Validation == nil {
+		return true
+	}
+
+	// the entries of clients that have left are removed when the clock is merged, whatever their value
+	clock := msg.Clock.Copy()
+	clock.Retire(s.Retired)
+	local := s.Clock.Load()
+	if s.Down || s.Recovering {
+		local = clock // the clock was lost, so the message can only be checked on its own
+	}
+	reason := s.Validation.Check(clock, local, s.isNode)
+	if s.isQuarantined(clientId) {
+		reason = "the client is quarantined"
+	}
+	if reason == "" {
+		return true
+	}
+
+	s.Rejected += 1
+	fmt.Println(fmt.Sprintf("[SERVER-VC%v] Message from client %d rejected, %s: '%s'", s.Clock.Load(), clientId, reason, msg.Message))
+	s.Trace.Record(trace.Event{Node: trace.Client(clientId), Type: trace.DROP, Peer: s.Node, MessageId: msg.MessageId, ClockBefore: msg.Clock.Copy(), Clock: msg.Clock.Copy(), Description: "rejected: " + reason})
+	if _, ok := s.Quarantined[clientId]; !ok {
+		s.Quarantined[clientId] = s.Validation.QuarantineUntil()
+		if s.Validation.Quarantine == 0 {
+			fmt.Println(fmt.Sprintf("[SERVER-VC%v] Client %d quarantined for the rest of the run", s.Clock.Load(), clientId))
+		} else {
+			fmt.Println(fmt.Sprintf("[SERVER-VC%v] Client %d quarantined for %v", s.Clock.Load(), clientId, s.Validation.Quarantine))
+		}
+	}
+	return false
+}
+
+// function to broadcast a tombstone in place of a broadcast that was rejected. The client already counted the broadcast
+// among its own, so its later broadcasts depend on it: the tombstone fills its place in the history and the hold-back
+// queues of the receivers, which deliver it without delivering anything, and the client's broadcasts are delivered
+// again once its quarantine is over
+func (s *Server) bury(clientId int, msg client.Message) {
+	seq := msg.Timestamp[clientId]
+	tombstone := client.Message{
+		Type: client.TOMBSTONE,
+		Message: fmt.Sprintf("rejected message %d of client %d", seq, clientId),
+		ClientId: clientId,
+		Timestamp: client.VectorClock{clientId: seq}, // only the broadcasts of the client before it have to be delivered first
+		MessageId: msg.MessageId,
+	}
+
+	s.Lock.Lock()
+	s.History[clientId] = append(s.History[clientId], tombstone)
+	s.Lock.Unlock()
+	s.sendMessage(tombstone)
+}
+
+// function to check if an id belongs to the server, to a client in the system or to a node of another region of
+// the federation. Must be called with the lock held
+func (s *Server) isNode(id int) bool {
+	_, ok := s.SendChannels[id]
+	return ok || id == s.Id || s.Remote[id]
+}
+
+// function to check if the messages of a client are still rejected, lifting its quarantine once it is over.
+// Must be called with the lock held
+func (s *Server) isQuarantined(clientId int) bool {
+	until, ok := s.Quarantined[clientId]
+	if !ok {
+		return false
+	}
+	if until.IsZero() || time.Now().Before(until) {
+		return true
+	}
+
+	delete(s.Quarantined, clientId)
+	fmt.Println(fmt.Sprintf("[SERVER-VC%v] Quarantine of client %d is over", s.Clock.Load(), clientId))
+	return false
+}
@@ -0,0 +1,139 @@
+package trace
+
+import (
+	"fmt"
+	"maps"
+)
+
+// Invariants checked by Check
+const (
+	CLOCK_CONDITION = "clock condition" // a message is sent with a smaller clock than it is received with
+	MONOTONICITY    = "monotonicity" // every step of a node increments its own entry, starting from the clock the previous step left
+	RECORDED_ORDER  = "recorded order" // a message is recorded as sent before it is recorded as received
+	HAPPENED_BEFORE = "happened-before" // the order of the vector clocks is the happened-before relation of the trace
+)
+
+// Violation is an event that breaks one of the invariants of a logical clock
+type Violation struct {
+	Invariant string
+	Event Event
+	Detail string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s violated by %s %s: %s", v.Invariant, v.Event.Node, Describe(v.Event), v.Detail)
+}
+
+// Checks the vector clocks of the events in the order they were recorded.
+// A clock that was read or updated outside the lock shows up as a step that does not continue
+// from the previous step of the node, or as a pair of events whose clocks are ordered differently
+// from the messages between them. Entries of clients that left are retired from the clocks,
+// so they are ignored along with the events of those clients.
+func Check(events []Event) []Violation {
+	violations := make([]Violation, 0)
+	retired := retiredIds(events)
+	sends := make(map[string]Event)
+	last := make(map[string]Event) // previous step of each node
+	steps := make([]Event, 0, len(events))
+
+	for _, event := range events {
+		if event.Type == DROP {
+			continue
+		}
+		steps = append(steps, event)
+
+		if previous, ok := last[event.Node]; ok && !maps.Equal(event.ClockBefore, previous.Clock) {
+			violations = append(violations, Violation{MONOTONICITY, event, fmt.Sprintf("the clock was %s before the step but the previous step left it at %s", clockString(event.ClockBefore), clockString(previous.Clock))})
+		}
+		_, id := splitNode(event.Node)
+		if event.Clock[id] <= event.ClockBefore[id] || !lessOrEqual(event.ClockBefore, event.Clock, retired) {
+			violations = append(violations, Violation{MONOTONICITY, event, fmt.Sprintf("the clock went from %s to %s", clockString(event.ClockBefore), clockString(event.Clock))})
+		}
+		last[event.Node] = event
+
+		switch event.Type {
+		case SEND:
+			sends[event.MessageId] = event
+		case RECEIVE:
+			send, ok := sends[event.MessageId]
+			if !ok {
+				violations = append(violations, Violation{RECORDED_ORDER, event, "the message was not recorded as sent before it was received"})
+			} else if !less(send.Clock, event.Clock, retired) {
+				violations = append(violations, Violation{CLOCK_CONDITION, event, fmt.Sprintf("the message was sent with clock %s and received with clock %s", clockString(send.Clock), clockString(event.Clock))})
+			}
+		}
+	}
+
+	return append(violations, checkHappenedBefore(steps, retired)...)
+}
+
+// Compares the order of the recorded vector clocks with the happened-before relation derived from
+// the messages in the trace. Only the first mismatch of every event is reported.
+func checkHappenedBefore(steps []Event, retired map[int]bool) []Violation {
+	violations := make([]Violation, 0)
+	ordered := CausalOrder(steps)
+	timestamps := VectorTimestamps(ordered)
+
+	for j, event := range ordered {
+		if isRetired(event.Node, retired) {
+			continue
+		}
+		for i := range j {
+			other := ordered[i]
+			if isRetired(other.Node, retired) {
+				continue
+			}
+
+			// the causal order never puts an event before one that happened before it
+			happenedBefore := lessOrEqual(timestamps[i], timestamps[j], nil)
+			if happenedBefore == less(other.Clock, event.Clock, retired) && !less(event.Clock, other.Clock, retired) {
+				continue
+			}
+
+			relation := "happened after"
+			if !happenedBefore {
+				relation = "is concurrent with"
+			}
+			violations = append(violations, Violation{HAPPENED_BEFORE, event, fmt.Sprintf("it %s %s %s, but the clocks are ordered otherwise", relation, other.Node, Describe(other))})
+			break
+		}
+	}
+	return violations
+}
+
+// ids of the clients that left the system during the trace
+func retiredIds(events []Event) map[int]bool {
+	retired := make(map[int]bool)
+	for _, event := range events {
+		if event.Type == SEND && event.Description == "leave" {
+			_, id := splitNode(event.Node)
+			retired[id] = true
+		}
+	}
+	return retired
+}
+
+func isRetired(node string, retired map[int]bool) bool {
+	prefix, id := splitNode(node)
+	return prefix != SERVER && retired[id]
+}
+
+// Checking if every entry of clock1 is at most the entry of clock2, treating missing entries as 0 and skipping the retired ones
+func lessOrEqual[K comparable](clock1 map[K]int, clock2 map[K]int, retired map[K]bool) bool {
+	for id, value := range clock1 {
+		if !retired[id] && value > clock2[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// Checking if clock1 is smaller than clock2 in the vector clock order. The retired entries are skipped
+// when comparing, but still tell the clocks apart since they were not retired yet when the event happened.
+func less(clock1 map[int]int, clock2 map[int]int, retired map[int]bool) bool {
+	return lessOrEqual(clock1, clock2, retired) && !lessOrEqual(clock2, clock1, nil)
+}
+
+func clockString(clock map[int]int) string {
+	return Event{Clock: clock}.ClockLabel()
+}
@@ -0,0 +1,172 @@
+package trace
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Regular expression telling ShiViz how to parse the log
+const ShiVizRegex = `(?<host>\S*) (?<clock>{.*})\n(?<event>.*)`
+
+// Writes the events as a log that can be loaded into ShiViz (https://bestchai.bitbucket.io/shiviz/).
+// ShiViz needs vector timestamps, so they are derived from the messages in the trace.
+func WriteShiViz(w io.Writer, events []Event) error {
+	ordered := CausalOrder(events)
+	clocks := VectorTimestamps(ordered)
+
+	if _, err := fmt.Fprintf(w, "%s\n\n", ShiVizRegex); err != nil {
+		return err
+	}
+
+	for i, event := range ordered {
+		clock, err := json.Marshal(clocks[i])
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s %s\n%s\n", event.Node, clock, Describe(event)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Orders the events so that every message is sent before it is received while keeping the
+// order of each node's events. Events stay in the order they were recorded whenever possible.
+func CausalOrder(events []Event) []Event {
+	sent := make(map[string]bool) // messages whose send event is part of the trace
+	queues := make(map[string][]int) // indexes of the events of each node
+	for i, event := range events {
+		if event.Type == SEND {
+			sent[event.MessageId] = true
+		}
+		queues[event.Node] = append(queues[event.Node], i)
+	}
+
+	ordered := make([]Event, 0, len(events))
+	emitted := make(map[string]bool) // messages whose send event has been ordered already
+	for len(ordered) < len(events) {
+		next, blocked := "", ""
+		for node, queue := range queues {
+			if len(queue) == 0 {
+				continue
+			}
+			head := events[queue[0]]
+			if blocked == "" || queue[0] < queues[blocked][0] {
+				blocked = node
+			}
+			if head.Type == RECEIVE && sent[head.MessageId] && !emitted[head.MessageId] {
+				continue
+			}
+			if next == "" || queue[0] < queues[next][0] {
+				next = node
+			}
+		}
+
+		// Only happens if the trace is broken, so the earliest event is taken to make progress
+		if next == "" {
+			next = blocked
+		}
+
+		event := events[queues[next][0]]
+		queues[next] = queues[next][1:]
+		if event.Type == SEND {
+			emitted[event.MessageId] = true
+		}
+		ordered = append(ordered, event)
+	}
+	return ordered
+}
+
+// Computes the vector timestamp of every event from the happened-before relation in the trace.
+// The events have to be in causal order.
+func VectorTimestamps(ordered []Event) []map[string]int {
+	current := make(map[string]map[string]int)
+	sendTimestamps := make(map[string]map[string]int)
+	timestamps := make([]map[string]int, len(ordered))
+
+	for i, event := range ordered {
+		clock, ok := current[event.Node]
+		if !ok {
+			clock = make(map[string]int)
+			current[event.Node] = clock
+		}
+
+		if event.Type == RECEIVE {
+			for node, value := range sendTimestamps[event.MessageId]{
+				clock[node] = max(clock[node], value)
+			}
+		}
+		clock[event.Node] += 1
+
+		timestamps[i] = copyTimestamp(clock)
+		if event.Type == SEND {
+			sendTimestamps[event.MessageId] = timestamps[i]
+		}
+	}
+	return timestamps
+}
+
+// Every node in the trace, the ones without an id first and the rest ordered by id
+func Nodes(events []Event) []string {
+	nodes := make([]string, 0)
+	for _, event := range events {
+		if !slices.Contains(nodes, event.Node) {
+			nodes = append(nodes, event.Node)
+		}
+	}
+
+	slices.SortFunc(nodes, func(a, b string) int {
+		prefixA, idA := splitNode(a)
+		prefixB, idB := splitNode(b)
+		return cmp.Or(cmp.Compare(idA, idB), cmp.Compare(prefixA, prefixB))
+	})
+	return nodes
+}
+
+// Description of an event used in logs and diagrams
+func Describe(event Event) string {
+	description := fmt.Sprintf("%s clock=%s", event.Type, event.ClockLabel())
+	switch event.Type {
+	case SEND, DROP:
+		description = fmt.Sprintf("%s to %s", description, event.Peer)
+	case RECEIVE:
+		description = fmt.Sprintf("%s from %s", description, event.Peer)
+	}
+	if event.MessageId != "" {
+		description = fmt.Sprintf("%s [%s]", description, event.MessageId)
+	}
+	if event.Description != "" {
+		description = fmt.Sprintf("%s: %s", description, event.Description)
+	}
+	return description
+}
+
+// Splitting a node name such as client-3 into its prefix and id, nodes without an id get -1.
+// The server of a region in a federation, such as server-2, gets the id of its entry in the vector clocks
+func splitNode(node string) (string, int) {
+	index := strings.LastIndex(node, "-")
+	if index == -1 {
+		return node, -1
+	}
+	id, err := strconv.Atoi(node[index + 1:])
+	if err != nil {
+		return node, -1
+	}
+	if node[:index] == SERVER {
+		return SERVER, -1 - id
+	}
+	return node[:index], id
+}
+
+func copyTimestamp(clock map[string]int) map[string]int {
+	timestamp := make(map[string]int, len(clock))
+	for node, value := range clock {
+		timestamp[node] = value
+	}
+	return timestamp
+}
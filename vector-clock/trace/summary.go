@@ -0,0 +1,73 @@
+package trace
+
+import "fmt"
+
+// Summary of how the clocks grew over a run and how much concurrency they detected, to compare topologies
+type Summary struct {
+	Nodes []NodeSummary
+	Messages int // messages sent
+	Dropped int // messages lost by the network
+	Delivered int // messages received
+	Pairs int // pairs of messages sent by different nodes
+	Concurrent int // pairs of messages sent by different nodes whose clocks are concurrent
+}
+
+type NodeSummary struct {
+	Node string
+	Sent int
+	Received int
+	Clock map[int]int // clock after the last event of the node
+}
+
+// Summarizes the events of a run by node and compares the clocks of every pair of messages
+func Summarize(events []Event) Summary {
+	summary := Summary{}
+	nodes := make(map[string]*NodeSummary)
+	for _, node := range Nodes(events) {
+		summary.Nodes = append(summary.Nodes, NodeSummary{Node: node})
+	}
+	for i := range summary.Nodes {
+		nodes[summary.Nodes[i].Node] = &summary.Nodes[i]
+	}
+
+	retired := retiredIds(events)
+	sends := make([]Event, 0)
+	for _, event := range events {
+		node := nodes[event.Node]
+		switch event.Type {
+		case SEND:
+			node.Sent += 1
+			summary.Messages += 1
+			for _, other := range sends {
+				if other.Node != event.Node {
+					summary.Pairs += 1
+					if !less(other.Clock, event.Clock, retired) && !less(event.Clock, other.Clock, retired) {
+						summary.Concurrent += 1
+					}
+				}
+			}
+			sends = append(sends, event)
+		case RECEIVE:
+			node.Received += 1
+			summary.Delivered += 1
+		case DROP:
+			summary.Dropped += 1
+			continue
+		}
+		node.Clock = event.Clock
+	}
+	return summary
+}
+
+// Lines describing the summary, one per node and one for the whole run
+func (s Summary) Lines() []string {
+	lines := make([]string, 0, len(s.Nodes) + 1)
+	for _, node := range s.Nodes {
+		lines = append(lines, fmt.Sprintf("%s: %d sent, %d received, final clock %s with %d entries", node.Node, node.Sent, node.Received, clockString(node.Clock), len(node.Clock)))
+	}
+	concurrent := 0.0
+	if s.Pairs > 0 {
+		concurrent = 100 * float64(s.Concurrent) / float64(s.Pairs)
+	}
+	return append(lines, fmt.Sprintf("%d messages sent, %d dropped, %d delivered, %d of %d pairs of messages from different nodes are concurrent (%.1f%%)", s.Messages, s.Dropped, s.Delivered, s.Concurrent, s.Pairs, concurrent))
+}
@@ -0,0 +1,157 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	SEND     = "send"
+	RECEIVE  = "receive"
+	DROP     = "drop" // message lost by the network after it was sent
+	INTERNAL = "internal"
+)
+
+const (
+	SERVER = "server" // name of the server in the trace, or prefix of the names of the servers of a federation
+)
+
+// Event is a single step in the run of a node, along with the node's clock before and after the step.
+// A drop is not a step of the node, so both clocks are the clock of the message that was dropped.
+type Event struct {
+	Node string `json:"node"`
+	Type string `json:"type"` // SEND | RECEIVE | DROP | INTERNAL
+	Peer string `json:"peer,omitempty"` // node on the other end of a message
+	MessageId string `json:"message_id,omitempty"` // links the send of a message to its receive
+	ClockBefore map[int]int `json:"clock_before"`
+	Clock map[int]int `json:"clock_after"` // vector clock keyed by node id, the server is -1 and the server of region k in a federation is -1-k
+	Description string `json:"description,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// Recorder keeps every event of a run in the order they were recorded, and writes each of them
+// as a JSON line to Output if it is set. A nil Recorder records nothing, so tracing can be left disabled.
+type Recorder struct {
+	Output io.Writer
+	events []Event
+	messageIds map[string]int
+	lock sync.Mutex
+}
+
+// name of a client in the trace
+func Client(id int) string {
+	return fmt.Sprintf("client-%d", id)
+}
+
+// name of the server of a region in a federation. The server of region k has the entry -1-k in the vector clocks
+func Server(region int) string {
+	return fmt.Sprintf("%s-%d", SERVER, region)
+}
+
+// entry of a node in the vector clocks, the inverse of Client and Server
+func NodeId(node string) int {
+	_, id := splitNode(node)
+	return id
+}
+
+// Clock value shown in diagrams
+func (e Event) ClockLabel() string {
+	return strings.TrimPrefix(fmt.Sprint(e.Clock), "map")
+}
+
+func (r *Recorder) Record(event Event) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	event.Time = time.Now()
+	r.events = append(r.events, event)
+
+	if r.Output != nil {
+		if err := json.NewEncoder(r.Output).Encode(event); err != nil {
+			fmt.Printf("Error occurred while writing the trace: %s\n", err)
+		}
+	}
+}
+
+// Stops writing events to Output and closes it. Events recorded afterwards are only kept in memory.
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	output := r.Output
+	r.Output = nil
+	if closer, ok := output.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Generates a unique id for a message sent by the given node
+func (r *Recorder) NewMessageId(node string) string {
+	if r == nil {
+		return ""
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.messageIds == nil {
+		r.messageIds = make(map[string]int)
+	}
+	r.messageIds[node] += 1
+	return fmt.Sprintf("%s/%d", node, r.messageIds[node])
+}
+
+// Every event recorded so far
+func (r *Recorder) Events() []Event {
+	if r == nil {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	events := make([]Event, len(r.events))
+	copy(events, r.events)
+	return events
+}
+
+// Reads the events written by a Recorder as JSON lines
+func ReadEvents(r io.Reader) ([]Event, error) {
+	events := make([]Event, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64 * 1024), 1024 * 1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// Writes the events to a file in one of the export formats
+func WriteFile(path string, write func(io.Writer, []Event) error, events []Event) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return write(file, events)
+}